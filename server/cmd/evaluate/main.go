@@ -0,0 +1,423 @@
+// Command evaluate measures search quality on a judged corpus.
+//
+// It ingests every PDF of the corpus directory for a temporary user, runs
+// the judged queries with each search type and reports recall@k, MRR and
+// nDCG@k. Besides the server's own types it evaluates hybrid search, text
+// and semantic results fused by reciprocal rank, and re-ranking of the
+// candidates by the embedder's cross-encoder:
+//
+//	go run ./cmd/evaluate -corpus ../testbench -judgments ../testbench/judgments.json
+//
+// The judgments file lists queries with their relevant documents (PDF file
+// names), optionally narrowed to a page and graded (default 1):
+//
+//	{"queries": [{"query": "goroutines", "relevant": [{"document": "golang.pdf", "page": 1, "grade": 2}]}]}
+//
+// Database, embedder and chunking settings come from the usual environment;
+// -json writes the full report for comparing runs with different settings.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/config"
+	"github.com/AndB0ndar/doc-archive/internal/db"
+	"github.com/AndB0ndar/doc-archive/internal/models"
+	"github.com/AndB0ndar/doc-archive/internal/repository"
+	"github.com/AndB0ndar/doc-archive/internal/service"
+)
+
+type judgments struct {
+	Queries []struct {
+		Query    string `json:"query"`
+		Relevant []struct {
+			Document string `json:"document"`
+			Page     int    `json:"page"`
+			Grade    int    `json:"grade"`
+		} `json:"relevant"`
+	} `json:"queries"`
+}
+
+type settings struct {
+	Corpus       string `json:"corpus"`
+	Documents    int    `json:"documents"`
+	Queries      int    `json:"queries"`
+	K            int    `json:"k"`
+	ChunkSize    int    `json:"chunk_size"`
+	ChunkOverlap int    `json:"chunk_overlap"`
+	EmbedderURL  string `json:"embedder_url"`
+}
+
+type queryReport struct {
+	Query string `json:"query"`
+	queryMetrics
+	Error string `json:"error,omitempty"`
+}
+
+type typeReport struct {
+	Type      string        `json:"type"`
+	RecallAtK float64       `json:"recall_at_k"`
+	MRR       float64       `json:"mrr"`
+	NDCG      float64       `json:"ndcg_at_k"`
+	Queries   []queryReport `json:"queries"`
+}
+
+type report struct {
+	Settings settings     `json:"settings"`
+	Results  []typeReport `json:"results"`
+}
+
+// searchMode is how a reported search type gets its results: the server
+// requests, fused when there are several, and optionally re-ranked.
+type searchMode struct {
+	requests []service.SearchRequest
+	rerank   bool
+}
+
+var (
+	textRequests     = []service.SearchRequest{{Type: "text"}}
+	semanticRequests = []service.SearchRequest{{Type: "semantic"}}
+	hybridRequests   = []service.SearchRequest{{Type: "text"}, {Type: "semantic"}}
+)
+
+var searchTypes = map[string]searchMode{
+	"text":            {requests: textRequests},
+	"semantic":        {requests: semanticRequests},
+	"semantic+mmr":    {requests: []service.SearchRequest{{Type: "semantic", MMR: true}}},
+	"hybrid":          {requests: hybridRequests},
+	"semantic+rerank": {requests: semanticRequests, rerank: true},
+	"hybrid+rerank":   {requests: hybridRequests, rerank: true},
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "evaluate:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	corpus := flag.String("corpus", "../testbench", "directory with the PDFs to ingest")
+	judgmentsPath := flag.String("judgments", "../testbench/judgments.json", "judgments file")
+	k := flag.Int("k", 10, "cutoff for recall and nDCG")
+	types := flag.String("types", "text,semantic,semantic+mmr,hybrid",
+		"search types to evaluate, also semantic+rerank and hybrid+rerank")
+	candidates := flag.Int("candidates", 50, "results per request fused or re-ranked")
+	rerankURL := flag.String("rerank-url", "",
+		"embedder serving /rerank, default the first EMBEDDER_URL")
+	chunkSize := flag.Int("chunk-size", 0, "override the chunk size")
+	chunkOverlap := flag.Int("chunk-overlap", -1, "override the chunk overlap")
+	jsonPath := flag.String("json", "", "write the JSON report to this file (- for stdout)")
+	keep := flag.Bool("keep", false, "keep the ingested documents and their user")
+	verbose := flag.Bool("v", false, "log ingestion progress")
+	flag.Parse()
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if *chunkSize > 0 {
+		cfg.ChunkSize = *chunkSize
+	}
+	if *chunkOverlap >= 0 {
+		cfg.ChunkOverlap = *chunkOverlap
+	}
+	var rr *reranker
+	for _, t := range strings.Split(*types, ",") {
+		mode, ok := searchTypes[t]
+		if !ok {
+			return fmt.Errorf("unknown search type %q", t)
+		}
+		if mode.rerank && rr == nil {
+			url := *rerankURL
+			if url == "" && len(cfg.Embedder.URLs) > 0 {
+				url = cfg.Embedder.URLs[0]
+			}
+			if url == "" {
+				return fmt.Errorf("search type %q needs -rerank-url", t)
+			}
+			rr = newReranker(strings.TrimSuffix(url, "/"))
+		}
+	}
+
+	var judged judgments
+	data, err := os.ReadFile(*judgmentsPath)
+	if err != nil {
+		return fmt.Errorf("read judgments: %w", err)
+	}
+	if err := json.Unmarshal(data, &judged); err != nil {
+		return fmt.Errorf("parse judgments: %w", err)
+	}
+
+	pool, err := db.NewPool(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := db.RunMigrations(pool, cfg.Database); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	docRepo := repository.NewDocumentRepository(pool)
+	chunkRepo := repository.NewChunkRepository(pool, cfg.Database.VectorIndex, cfg.EmbeddingDimension)
+	userRepo := repository.NewUserRepository(pool)
+	embedder, err := service.NewEmbedder(cfg)
+	if err != nil {
+		return err
+	}
+	if err := service.CheckEmbeddings(ctx, cfg, chunkRepo, embedder); err != nil {
+		return err
+	}
+	if err := chunkRepo.CheckVectorIndex(); err != nil {
+		return err
+	}
+	// Repeated runs embed the same corpus, the cache skips the embedder
+	embeddings := service.NewEmbeddingCache(
+		embedder, cfg.EmbeddingDimension, cfg.EmbeddingCacheSize,
+		repository.NewEmbeddingCacheRepository(pool),
+	)
+	docService := service.NewDocumentService(cfg, docRepo, chunkRepo, embeddings)
+	searchService := service.NewSearchService(cfg, chunkRepo, docRepo, embeddings)
+
+	user, err := userRepo.Create(
+		ctx, fmt.Sprintf("evaluate-%d@localhost", time.Now().UnixNano()), "evaluate",
+	)
+	if err != nil {
+		return err
+	}
+	if !*keep {
+		defer userRepo.Delete(ctx, user.ID)
+	}
+
+	docIDs, err := ingest(docService, *corpus, user.ID)
+	if err != nil {
+		return err
+	}
+
+	rep := report{Settings: settings{
+		Corpus:       *corpus,
+		Documents:    len(docIDs),
+		Queries:      len(judged.Queries),
+		K:            *k,
+		ChunkSize:    cfg.ChunkSize,
+		ChunkOverlap: cfg.ChunkOverlap,
+		EmbedderURL:  strings.Join(cfg.Embedder.URLs, ","),
+	}}
+
+	for _, name := range strings.Split(*types, ",") {
+		tr := typeReport{Type: name}
+		for _, q := range judged.Queries {
+			var units []unit
+			for _, r := range q.Relevant {
+				id, ok := docIDs[r.Document]
+				if !ok {
+					return fmt.Errorf("query %q: document %q is not in the corpus", q.Query, r.Document)
+				}
+				units = append(units, unit{docID: id, page: r.Page, grade: max(r.Grade, 1)})
+			}
+
+			qr := queryReport{Query: q.Query}
+			results, err := search(
+				searchService, rr, searchTypes[name], q.Query, user.ID, *k, *candidates,
+			)
+			if err != nil {
+				qr.Error = err.Error()
+			} else {
+				qr.queryMetrics = score(results, units, *k)
+			}
+			tr.RecallAtK += qr.Recall
+			tr.MRR += qr.ReciprocalRank
+			tr.NDCG += qr.NDCG
+			tr.Queries = append(tr.Queries, qr)
+		}
+		if n := float64(len(judged.Queries)); n > 0 {
+			tr.RecallAtK /= n
+			tr.MRR /= n
+			tr.NDCG /= n
+		}
+		rep.Results = append(rep.Results, tr)
+	}
+
+	// Keep stdout parseable when the JSON report goes there
+	out := os.Stdout
+	if *jsonPath == "-" {
+		out = os.Stderr
+	}
+	printReport(out, rep)
+	return writeJSON(*jsonPath, rep)
+}
+
+// ingest processes every PDF of dir and returns document IDs by file name.
+// Title, authors and year come from the front matter or first heading of
+// the Markdown source next to the PDF, when there is one.
+func ingest(docService *service.DocumentService, dir string, userID int) (map[string]int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pdf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no PDFs in %s", dir)
+	}
+
+	ids := make(map[string]int, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		doc := sourceMetadata(strings.TrimSuffix(path, ".pdf") + ".md")
+		if doc.Title == "" {
+			doc.Title = strings.TrimSuffix(filepath.Base(path), ".pdf")
+		}
+		doc.FilePath = path
+		doc.FileSize = info.Size()
+		doc.UserID = userID
+
+		id, err := docService.Ingest(&doc)
+		if err != nil {
+			return nil, fmt.Errorf("ingest %s: %w", path, err)
+		}
+		ids[filepath.Base(path)] = id
+		slog.Info("ingested", "file", path, "id", id)
+	}
+	return ids, nil
+}
+
+func sourceMetadata(mdPath string) models.Document {
+	var doc models.Document
+	data, err := os.ReadFile(mdPath)
+	if err != nil {
+		return doc
+	}
+	lines := strings.Split(string(data), "\n")
+	inFrontMatter := len(lines) > 0 && strings.TrimSpace(lines[0]) == "---"
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if inFrontMatter {
+			if i > 0 && line == "---" {
+				inFrontMatter = false
+				continue
+			}
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "title":
+				doc.Title = value
+			case "author", "authors":
+				doc.Authors = &value
+			case "year":
+				var year int
+				if _, err := fmt.Sscanf(value, "%d", &year); err == nil {
+					doc.Year = &year
+				}
+			}
+			continue
+		}
+		if title, ok := strings.CutPrefix(line, "# "); ok && doc.Title == "" {
+			doc.Title = title
+		}
+	}
+	return doc
+}
+
+// search runs the requests of mode for q and returns the top k results.
+// Fused or re-ranked modes take candidates results of every request.
+func search(
+	s *service.SearchService,
+	rr *reranker,
+	mode searchMode,
+	q string,
+	userID, k, candidates int,
+) ([]models.ChunkSearchResponse, error) {
+	query, err := service.ParseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	limit := k
+	if len(mode.requests) > 1 || mode.rerank {
+		limit = max(k, candidates)
+	}
+
+	lists := make([][]models.ChunkSearchResponse, 0, len(mode.requests))
+	for _, base := range mode.requests {
+		req := base
+		req.UserID = userID
+		req.Limit = limit
+		query.Apply(&req)
+		results, err := s.Search(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, results)
+	}
+	results := lists[0]
+	if len(lists) > 1 {
+		results = fuse(lists...)
+	}
+	if mode.rerank {
+		results, err = rr.rerank(ctx, query.Text, results)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results[:min(k, len(results))], nil
+}
+
+func printReport(w io.Writer, rep report) {
+	s := rep.Settings
+	fmt.Fprintf(w,
+		"%d documents, %d queries, k=%d, chunk size %d, overlap %d\n\n",
+		s.Documents, s.Queries, s.K, s.ChunkSize, s.ChunkOverlap,
+	)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "type\trecall@%d\tMRR\tnDCG@%d\terrors\n", s.K, s.K)
+	for _, r := range rep.Results {
+		errors := 0
+		for _, q := range r.Queries {
+			if q.Error != "" {
+				errors++
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%.3f\t%.3f\t%.3f\t%d\n", r.Type, r.RecallAtK, r.MRR, r.NDCG, errors)
+	}
+	tw.Flush()
+}
+
+func writeJSON(path string, rep report) error {
+	if path == "" {
+		return nil
+	}
+	out := os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("create report: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+// unit is a relevant document, or a page of it when page > 0.
+type unit struct {
+	docID int
+	page  int
+	grade int
+}
+
+func (u unit) hitBy(r models.ChunkSearchResponse) bool {
+	if r.DocumentID != u.docID {
+		return false
+	}
+	if u.page == 0 {
+		return true
+	}
+	return r.PageStart != nil && r.PageEnd != nil &&
+		*r.PageStart <= u.page && u.page <= *r.PageEnd
+}
+
+type queryMetrics struct {
+	Recall         float64 `json:"recall"`
+	ReciprocalRank float64 `json:"reciprocal_rank"`
+	NDCG           float64 `json:"ndcg"`
+}
+
+// score rates the top k results against the relevant units. A result
+// gains the highest grade among the units it hits first, so several
+// chunks of one relevant document are not rewarded twice.
+func score(results []models.ChunkSearchResponse, units []unit, k int) queryMetrics {
+	var m queryMetrics
+	if len(units) == 0 {
+		return m
+	}
+	hit := make([]bool, len(units))
+	hits := 0
+	dcg := 0.0
+	for rank, r := range results[:min(k, len(results))] {
+		gain := 0
+		relevant := false
+		for i, u := range units {
+			if !u.hitBy(r) {
+				continue
+			}
+			relevant = true
+			if !hit[i] {
+				hit[i] = true
+				hits++
+				gain = max(gain, u.grade)
+			}
+		}
+		if relevant && m.ReciprocalRank == 0 {
+			m.ReciprocalRank = 1 / float64(rank+1)
+		}
+		dcg += discounted(gain, rank)
+	}
+
+	grades := make([]int, len(units))
+	for i, u := range units {
+		grades[i] = u.grade
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(grades)))
+	idcg := 0.0
+	for rank, g := range grades[:min(k, len(grades))] {
+		idcg += discounted(g, rank)
+	}
+
+	m.Recall = float64(hits) / float64(len(units))
+	if idcg > 0 {
+		m.NDCG = dcg / idcg
+	}
+	return m
+}
+
+func discounted(grade, rank int) float64 {
+	return (math.Pow(2, float64(grade)) - 1) / math.Log2(float64(rank+2))
+}
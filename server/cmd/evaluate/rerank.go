@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+// Smoothing constant of reciprocal rank fusion, as in the original paper
+const rrfK = 60
+
+// Cross-encoder scoring of a few dozen passages runs well over the
+// embedding timeout on CPU
+const rerankTimeout = 2 * time.Minute
+
+// fuse merges ranked lists by reciprocal rank fusion: a chunk scores
+// the sum of 1/(rrfK + rank) over the lists it appears in.
+func fuse(lists ...[]models.ChunkSearchResponse) []models.ChunkSearchResponse {
+	scores := make(map[int64]float64)
+	var fused []models.ChunkSearchResponse
+	for _, list := range lists {
+		for rank, r := range list {
+			if _, seen := scores[r.ChunkID]; !seen {
+				fused = append(fused, r)
+			}
+			scores[r.ChunkID] += 1 / float64(rrfK+rank+1)
+		}
+	}
+	for i := range fused {
+		fused[i].Similarity = scores[fused[i].ChunkID]
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Similarity > fused[j].Similarity
+	})
+	return fused
+}
+
+// reranker scores query and passage pairs with the cross-encoder behind
+// the bundled embedder's /rerank (RERANK_MODEL_NAME must be set there).
+type reranker struct {
+	url        string
+	httpClient *http.Client
+}
+
+func newReranker(url string) *reranker {
+	return &reranker{url: url, httpClient: &http.Client{Timeout: rerankTimeout}}
+}
+
+// rerank orders results by their cross-encoder score for query.
+func (r *reranker) rerank(
+	ctx context.Context, query string, results []models.ChunkSearchResponse,
+) ([]models.ChunkSearchResponse, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+	texts := make([]string, len(results))
+	for i, res := range results {
+		texts[i] = res.Content
+	}
+	body, err := json.Marshal(map[string]any{"query": query, "texts": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, r.url+"/rerank", bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rerank: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rerank: %s", resp.Status)
+	}
+	var out struct {
+		Scores []float64 `json:"scores"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode rerank response: %w", err)
+	}
+	if len(out.Scores) != len(results) {
+		return nil, fmt.Errorf(
+			"rerank returned %d scores for %d texts", len(out.Scores), len(results),
+		)
+	}
+
+	reranked := make([]models.ChunkSearchResponse, len(results))
+	copy(reranked, results)
+	for i := range reranked {
+		reranked[i].Similarity = out.Scores[i]
+	}
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Similarity > reranked[j].Similarity
+	})
+	return reranked, nil
+}
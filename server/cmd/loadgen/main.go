@@ -0,0 +1,301 @@
+// Command loadgen drives a running server with concurrent uploads,
+// searches and document listings and reports latency percentiles, error
+// rates and throughput per endpoint.
+//
+// For a self-contained run start the stub embedder, point the server at
+// it and then run the load:
+//
+//	go run ./cmd/loadgen -url "" -stub-embedder :5001 &
+//	EMBEDDER_URL=http://localhost:5001 go run ./cmd/server &
+//	go run ./cmd/loadgen -duration 1m
+//
+// With an empty -url loadgen only runs the stub embedder until interrupted.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var defaultQueries = []string{
+	"goroutines and channels",
+	"semantic search with embeddings",
+	"pgvector nearest neighbor",
+	"fuzzy search pg_trgm",
+	"python machine learning",
+	"docker compose deployment",
+	`"vector database"`,
+	"author:pike concurrency",
+	"year:>=2020 search",
+	"flask web interface",
+}
+
+type operation struct {
+	name   string
+	weight int
+	run    func(ctx context.Context, c *client) error
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	baseURL := flag.String("url", "http://localhost:8080", "server base URL, empty to only run the stub embedder")
+	concurrency := flag.Int("concurrency", 8, "concurrent workers")
+	duration := flag.Duration("duration", 30*time.Second, "test duration")
+	mix := flag.String("mix", "search=80,documents=15,upload=5", "operation weights: search, documents, upload")
+	types := flag.String("types", "text,semantic", "search types used by search operations")
+	queriesPath := flag.String("queries", "", "file with one search query per line (default: built-in list)")
+	pdfPath := flag.String("pdf", "../testbench/golang.pdf", "PDF sent by upload operations")
+	timeout := flag.Duration("timeout", 30*time.Second, "per-request timeout")
+	stubAddr := flag.String("stub-embedder", "", "serve a stub embedder on this address, e.g. :5001")
+	stubLatency := flag.Duration("stub-latency", 0, "latency added to every stub embedding call")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *stubAddr != "" {
+		ln, err := net.Listen("tcp", *stubAddr)
+		if err != nil {
+			return fmt.Errorf("listen stub embedder: %w", err)
+		}
+		stub := &http.Server{Handler: newStubEmbedder(*stubLatency).handler()}
+		go stub.Serve(ln)
+		defer stub.Close()
+		fmt.Fprintf(os.Stderr, "stub embedder listening on %s\n", ln.Addr())
+	}
+	if *baseURL == "" {
+		if *stubAddr == "" {
+			return errors.New("nothing to do: set -url or -stub-embedder")
+		}
+		<-ctx.Done()
+		return nil
+	}
+
+	queries := defaultQueries
+	if *queriesPath != "" {
+		var err error
+		if queries, err = readLines(*queriesPath); err != nil {
+			return err
+		}
+	}
+	pdf, err := os.ReadFile(*pdfPath)
+	if err != nil {
+		return fmt.Errorf("read pdf: %w", err)
+	}
+	searchTypes := strings.Split(*types, ",")
+
+	ops := map[string]operation{
+		"search": {name: "search", run: func(ctx context.Context, c *client) error {
+			q := url.Values{
+				"q":    {queries[rand.IntN(len(queries))]},
+				"type": {searchTypes[rand.IntN(len(searchTypes))]},
+			}
+			return c.do(ctx, "GET /search?type="+q.Get("type"), http.MethodGet, "/search?"+q.Encode(), nil, "")
+		}},
+		"documents": {name: "documents", run: func(ctx context.Context, c *client) error {
+			return c.do(ctx, "GET /documents", http.MethodGet, "/documents?limit=20", nil, "")
+		}},
+		"upload": {name: "upload", run: func(ctx context.Context, c *client) error {
+			body, contentType, err := uploadBody(filepath.Base(*pdfPath), pdf)
+			if err != nil {
+				return err
+			}
+			return c.do(ctx, "POST /upload", http.MethodPost, "/upload", body, contentType)
+		}},
+	}
+	weighted, err := parseMix(*mix, ops)
+	if err != nil {
+		return err
+	}
+
+	c := &client{
+		base: strings.TrimSuffix(*baseURL, "/"),
+		http: &http.Client{Timeout: *timeout},
+		rec:  newRecorder(),
+	}
+	if err := c.register(ctx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "running %d workers for %s against %s\n", *concurrency, *duration, c.base)
+	runCtx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range *concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for runCtx.Err() == nil {
+				pick(weighted).run(runCtx, c)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.rec.print(os.Stdout, time.Since(start))
+	return nil
+}
+
+type client struct {
+	base  string
+	http  *http.Client
+	token string
+	rec   *recorder
+}
+
+// register creates a throwaway user and keeps its token.
+func (c *client) register(ctx context.Context) error {
+	creds, _ := json.Marshal(map[string]string{
+		"email":    fmt.Sprintf("loadgen-%d@localhost", time.Now().UnixNano()),
+		"password": "loadgen-password",
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+"/register", bytes.NewReader(creds))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("register: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("register: status %s", resp.Status)
+	}
+	var auth struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return fmt.Errorf("register: %w", err)
+	}
+	c.token = auth.Token
+	return nil
+}
+
+// do sends one request and records it under endpoint. Requests cut short
+// by the end of the run are not recorded.
+func (c *client) do(
+	ctx context.Context, endpoint, method, path string, body io.Reader, contentType string,
+) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if err == nil && resp.StatusCode >= 400 {
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	c.rec.record(endpoint, time.Since(start), err)
+	return err
+}
+
+func uploadBody(filename string, pdf []byte) (io.Reader, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, "", err
+	}
+	fw.Write(pdf)
+	mw.WriteField("title", "loadgen "+filename)
+	mw.WriteField("category", "loadgen")
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, mw.FormDataContentType(), nil
+}
+
+// parseMix expands "search=80,upload=5" into operations with weights.
+func parseMix(mix string, ops map[string]operation) ([]operation, error) {
+	var weighted []operation
+	for _, part := range strings.Split(mix, ",") {
+		name, w, ok := strings.Cut(strings.TrimSpace(part), "=")
+		op, known := ops[name]
+		weight, err := strconv.Atoi(w)
+		if !ok || !known || err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid mix entry %q", part)
+		}
+		if weight > 0 {
+			op.weight = weight
+			weighted = append(weighted, op)
+		}
+	}
+	if len(weighted) == 0 {
+		return nil, errors.New("mix has no operations")
+	}
+	return weighted, nil
+}
+
+func pick(ops []operation) operation {
+	total := 0
+	for _, op := range ops {
+		total += op.weight
+	}
+	n := rand.IntN(total)
+	for _, op := range ops {
+		if n < op.weight {
+			return op
+		}
+		n -= op.weight
+	}
+	return ops[len(ops)-1]
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open queries: %w", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no queries in %s", path)
+	}
+	return lines, sc.Err()
+}
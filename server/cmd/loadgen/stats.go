@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type endpointStats struct {
+	latencies []time.Duration
+	errors    int
+}
+
+// recorder collects request outcomes per endpoint from all workers.
+type recorder struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointStats
+}
+
+func newRecorder() *recorder {
+	return &recorder{endpoints: make(map[string]*endpointStats)}
+}
+
+func (r *recorder) record(endpoint string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.endpoints[endpoint]
+	if !ok {
+		s = &endpointStats{}
+		r.endpoints[endpoint] = s
+	}
+	s.latencies = append(s.latencies, latency)
+	if err != nil {
+		s.errors++
+	}
+}
+
+func (r *recorder) print(w io.Writer, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.endpoints))
+	for name := range r.endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "endpoint\trequests\terrors\terror %\treq/s\tp50\tp90\tp99\tmax\t")
+	for _, name := range names {
+		s := r.endpoints[name]
+		sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+		n := len(s.latencies)
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\t%s\t%s\t%s\t%s\t\n",
+			name, n, s.errors, 100*float64(s.errors)/float64(n),
+			float64(n)/elapsed.Seconds(),
+			round(percentile(s.latencies, 0.50)),
+			round(percentile(s.latencies, 0.90)),
+			round(percentile(s.latencies, 0.99)),
+			round(s.latencies[n-1]),
+		)
+	}
+	tw.Flush()
+}
+
+// percentile of sorted latencies, nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func round(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(100 * time.Microsecond)
+}
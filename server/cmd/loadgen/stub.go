@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/service"
+)
+
+const stubDimension = 384
+
+// stubEmbedder serves the /embed and /health contract of the Python
+// embedder with the vectors of the hash provider, so the server can run
+// under load without a model.
+type stubEmbedder struct {
+	latency  time.Duration
+	embedder service.Embedder
+}
+
+func newStubEmbedder(latency time.Duration) *stubEmbedder {
+	return &stubEmbedder{latency: latency, embedder: service.NewHashEmbedder(stubDimension)}
+}
+
+func (s *stubEmbedder) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /embed", s.embed)
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "ok", "embed_model": s.embedder.Model(),
+		})
+	})
+	return mux
+}
+
+func (s *stubEmbedder) embed(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Texts []string `json:"texts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	time.Sleep(s.latency)
+
+	embeddings := make([][]float32, len(req.Texts))
+	for i, text := range req.Texts {
+		embedding, err := s.embedder.Embed(r.Context(), text)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		embeddings[i] = embedding
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"embeddings": embeddings})
+}
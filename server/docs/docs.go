@@ -15,43 +15,26 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/documents": {
+        "/admin/backfill": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Возвращает метаданные всех загруженных документов.",
+                "description": "Возвращает ход текущего или последнего прохода фоновой задачи, которая\nвычисляет недостающие эмбеддинги фрагментов и документов, и сколько\nфрагментов и документов всё ещё без эмбеддинга. Только для администраторов.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "documents"
-                ],
-                "summary": "Список документов",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Максимальное количество документов на странице (по умолчанию 20, макс 100)",
-                        "name": "limit",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Смещение от начала списка (по умолчанию 0)",
-                        "name": "offset",
-                        "in": "query"
-                    }
+                    "admin"
                 ],
+                "summary": "Статус дозаполнения эмбеддингов",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Document"
-                            }
+                            "$ref": "#/definitions/models.BackfillStatus"
                         }
                     },
                     "401": {
@@ -60,172 +43,235 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
-                        "description": "Failed to fetch documents",
+                        "description": "Failed to get backfill status",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            }
-        },
-        "/documents/{id}": {
-            "get": {
+            },
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Возвращает метаданные документа по ID.",
+                "description": "Запускает внеочередной проход фоновой задачи: документы без фрагментов\nобрабатываются заново, фрагменты без эмбеддинга вычисляются пакетами\nс ограничением скорости (BACKFILL_BATCH_SIZE, BACKFILL_RATE), затем\nпересчитываются эмбеддинги затронутых документов. Ход прохода\nвозвращает GET /admin/backfill. Только для администраторов.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "documents"
-                ],
-                "summary": "Получить документ",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "ID документа",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "Запустить дозаполнение эмбеддингов",
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "$ref": "#/definitions/models.Document"
+                            "$ref": "#/definitions/models.BackfillStatus"
                         }
                     },
-                    "400": {
-                        "description": "Invalid document ID",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "Document not found",
+                    "409": {
+                        "description": "Backfill is already running",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/answer": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Удаляет документ по ID и его PDF-файл.",
+                "description": "Находит релевантные фрагменты и генерирует ответ с помощью LLM.\nОтвет содержит ссылки на источники в формате [doc_id:chunk_index].\nПри stream=true или Accept: text/event-stream ответ передаётся через SSE,\nпри Accept: application/x-ndjson — строками JSON {\"event\", \"data\"}:\nсобытия sources, delta, затем summary (models.AnswerSummary) или error.",
+                "produces": [
+                    "application/json",
+                    "text/event-stream",
+                    "application/x-ndjson"
+                ],
                 "tags": [
-                    "documents"
+                    "search"
                 ],
-                "summary": "Удалить документ",
+                "summary": "Ответ на вопрос (RAG)",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "ID документа",
-                        "name": "id",
-                        "in": "path",
+                        "type": "string",
+                        "description": "Вопрос",
+                        "name": "q",
+                        "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Тип поиска фрагментов: semantic (по умолчанию) или text",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество фрагментов в контексте",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Потоковая передача ответа (SSE)",
+                        "name": "stream",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Категории (можно несколько)",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "ID документов (можно несколько)",
+                        "name": "document_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Диверсификация фрагментов контекста (MMR)",
+                        "name": "mmr",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Баланс релевантности и разнообразия от 0 до 1 (по умолчанию 0.5)",
+                        "name": "mmr_lambda",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AnswerResponse"
+                        }
                     },
                     "400": {
-                        "description": "Invalid document ID",
+                        "description": "Bad Request",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "404": {
-                        "description": "Document not found",
-                        "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
-                    "500": {
-                        "description": "Failed to delete file or database record",
+                    "503": {
+                        "description": "Service Unavailable",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/login": {
-            "post": {
-                "description": "Аутентификация пользователя, получение JWT.",
-                "consumes": [
-                    "application/json"
+        "/conversations": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Возвращает диалоги, отсортированные по времени последнего сообщения.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "chat"
                 ],
-                "summary": "Вход в систему",
+                "summary": "Список диалогов",
                 "parameters": [
                     {
-                        "description": "Учётные данные",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.LoginRequest"
-                        }
+                        "type": "integer",
+                        "description": "Максимальное количество диалогов (по умолчанию 20, макс 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение от начала списка (по умолчанию 0)",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.AuthResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Conversation"
                             }
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch conversations",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
-            }
-        },
-        "/register": {
+            },
             "post": {
-                "description": "Создаёт нового пользователя с email и паролем.",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Создаёт диалог, опционально ограниченный документами или категориями.",
                 "consumes": [
                     "application/json"
                 ],
@@ -233,17 +279,16 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "chat"
                 ],
-                "summary": "Регистрация пользователя",
+                "summary": "Создать диалог",
                 "parameters": [
                     {
-                        "description": "Данные для регистрации",
+                        "description": "Параметры диалога",
                         "name": "request",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.RegisterRequest"
+                            "$ref": "#/definitions/models.CreateConversationRequest"
                         }
                     }
                 ],
@@ -251,97 +296,940 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.AuthResponse"
+                            "$ref": "#/definitions/models.Conversation"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "type": "string"
                         }
                     },
-                    "409": {
-                        "description": "Conflict",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create conversation",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/search": {
+        "/conversations/{id}": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Полнотекстовый или семантический поиск по содержимому.",
+                "description": "Возвращает диалог и историю сообщений с цитатами.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "search"
+                    "chat"
                 ],
-                "summary": "Поиск документов",
+                "summary": "Получить диалог",
                 "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Поисковый запрос",
-                        "name": "q",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Тип поиска: text (по умолчанию) или vector",
-                        "name": "type",
-                        "in": "query"
-                    },
                     {
                         "type": "integer",
-                        "description": "Максимальное количество результатов (макс 100)",
-                        "name": "limit",
-                        "in": "query"
+                        "description": "ID диалога",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.ChunkSearchResponse"
-                            }
+                            "$ref": "#/definitions/models.ConversationWithMessages"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid conversation ID",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "type": "string"
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "type": "string"
                         }
-                    }
-                }
-            }
-        },
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Удаляет диалог по ID.",
+                "tags": [
+                    "chat"
+                ],
+                "summary": "Удалить диалог",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID диалога",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid conversation ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/conversations/{id}/messages": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Переформулирует вопрос с учётом истории, ищет фрагменты документов\nпользователя и сохраняет ответ LLM вместе с цитатами.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chat"
+                ],
+                "summary": "Сообщение в диалог",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID диалога",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Сообщение",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PostMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.PostMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Answer service unavailable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает метаданные всех загруженных документов.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Список документов",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Максимальное количество документов на странице (по умолчанию 20, макс 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение от начала списка (по умолчанию 0)",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Document"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch documents",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает метаданные документа по ID.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Получить документ",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID документа",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Document"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid document ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Удаляет документ по ID и его PDF-файл.",
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Удалить документ",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID документа",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid document ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete file or database record",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/search": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Полнотекстовый или семантический поиск по фрагментам одного документа.\nРезультаты упорядочены по страницам и содержат номера страниц,\nпозиции совпадений и фрагменты с подсветкой.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Поиск по документу",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID документа",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Поисковый запрос (с поддержкой фраз и исключений)",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Тип поиска: text (по умолчанию) или semantic",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальное количество фрагментов (макс 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Минимальная оценка результата от 0 до 1",
+                        "name": "min_score",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Длина фрагмента в символах (по умолчанию 200)",
+                        "name": "fragment_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество фрагментов на результат (по умолчанию 3, макс 10)",
+                        "name": "fragments",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.DocumentHitsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid document ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Embedding service unavailable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/similar": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Ищет ближайшие документы по среднему эмбеддингу фрагментов.\nСам документ в выдачу не входит.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Похожие документы",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID документа",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальное количество документов (по умолчанию 10, макс 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SimilarDocument"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid document ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Document is not processed yet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Аутентификация пользователя, получение JWT.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Вход в систему",
+                "parameters": [
+                    {
+                        "description": "Учётные данные",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AuthResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/register": {
+            "post": {
+                "description": "Создаёт нового пользователя с email и паролем.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Регистрация пользователя",
+                "parameters": [
+                    {
+                        "description": "Данные для регистрации",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RegisterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.AuthResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/search": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Полнотекстовый или семантический поиск по содержимому. С type=document\nранжируются сами документы (поле document_results) по вектору из названия,\nавторов, аннотации (или первой страницы) и центроида фрагментов.\nЗапрос поддерживает язык фильтров: author:pike year:\u003e=2010 category:go\n\"точная фраза\" -исключение, диапазоны year:2010..2015 и uploaded:\u003e=2024-01-01.\nПри Accept: application/x-ndjson или text/event-stream результаты передаются\nпо мере готовности этапов: candidates (до MMR), results, documents или document_results,\nзатем summary (models.SearchSummary) или error.",
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "search"
+                ],
+                "summary": "Поиск документов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Поисковый запрос (с поддержкой языка фильтров)",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Тип поиска: text (по умолчанию), vector (semantic) или document",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальное количество результатов (макс 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Минимальная оценка результата от 0 до 1",
+                        "name": "min_score",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Категории (можно несколько)",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Год публикации от",
+                        "name": "year_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Год публикации до",
+                        "name": "year_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Подстрока в авторах",
+                        "name": "author",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "ID документов (можно несколько)",
+                        "name": "document_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Загружен не раньше (YYYY-MM-DD или RFC 3339)",
+                        "name": "uploaded_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Загружен не позже (YYYY-MM-DD или RFC 3339)",
+                        "name": "uploaded_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Форматы файлов, например pdf",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Вернуть фрагменты с подсветкой (\u003cmark\u003e) и позиции совпадений",
+                        "name": "highlight",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Длина фрагмента в символах (по умолчанию 200)",
+                        "name": "fragment_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество фрагментов на результат (по умолчанию 3, макс 10)",
+                        "name": "fragments",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Группировка: document — поле documents, один элемент на документ",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Фрагментов на документ при группировке (по умолчанию 3, макс 10)",
+                        "name": "group_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Диверсификация семантической выдачи (maximal marginal relevance)",
+                        "name": "mmr",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Баланс релевантности и разнообразия от 0 до 1 (по умолчанию 0.5)",
+                        "name": "mmr_lambda",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы (next_cursor из предыдущего ответа)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Посчитать приблизительное количество совпадений",
+                        "name": "total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Фасеты: category, year, author, format",
+                        "name": "facets",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Значений на фасет (по умолчанию 10, макс 100)",
+                        "name": "facet_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Исходные оценки, применённые фильтры и время этапов; администраторам также разбор запроса и план SQL",
+                        "name": "explain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "hnsw.ef_search для семантического поиска (1..1000, по умолчанию по limit)",
+                        "name": "ef_search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ivfflat.probes для семантического поиска (1..1000, по умолчанию IVFFLAT_PROBES)",
+                        "name": "probes",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SearchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/search/similar": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Разбивает текст (JSON {\"text\": ...} или поле формы text) либо PDF-файл\nна фрагменты тем же конвейером, что и загрузку, и ранжирует документы\nпо близости их фрагментов. Для каждого документа возвращаются пары\n«фрагмент запроса — фрагмент документа».",
+                "consumes": [
+                    "application/json",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "search"
+                ],
+                "summary": "Поиск по тексту или файлу",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF-файл",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Текст",
+                        "name": "text",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальное количество документов (макс 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Пар фрагментов на документ (по умолчанию 3, макс 10)",
+                        "name": "pairs",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Категории (можно несколько)",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "ID документов (можно несколько)",
+                        "name": "document_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimilarSearchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/upload": {
             "post": {
                 "security": [
@@ -422,29 +1310,421 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.AuthResponse": {
+        }
+    },
+    "definitions": {
+        "models.AnswerResponse": {
+            "type": "object",
+            "properties": {
+                "answer": {
+                    "type": "string"
+                },
+                "citations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Citation"
+                    }
+                },
+                "question": {
+                    "type": "string"
+                },
+                "sources": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChunkSearchResponse"
+                    }
+                }
+            }
+        },
+        "models.AuthResponse": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                },
+                "user": {
+                    "$ref": "#/definitions/models.User"
+                }
+            }
+        },
+        "models.BackfillStatus": {
+            "type": "object",
+            "properties": {
+                "chunks_embedded": {
+                    "type": "integer"
+                },
+                "chunks_failed": {
+                    "type": "integer"
+                },
+                "documents_failed": {
+                    "type": "integer"
+                },
+                "documents_reprocessed": {
+                    "description": "Documents without chunks extracted again",
+                    "type": "integer"
+                },
+                "documents_updated": {
+                    "description": "Document vectors recomputed from their chunks, title and abstract",
+                    "type": "integer"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "missing_chunks": {
+                    "description": "Counted when the status is requested",
+                    "type": "integer"
+                },
+                "missing_documents": {
+                    "type": "integer"
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "started_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ChatMessage": {
+            "type": "object",
+            "properties": {
+                "citations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Citation"
+                    }
+                },
+                "content": {
+                    "type": "string"
+                },
+                "conversation_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "role": {
+                    "description": "user or assistant",
+                    "type": "string"
+                },
+                "search_query": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ChunkSearchResponse": {
+            "type": "object",
+            "properties": {
+                "authors": {
+                    "type": "string"
+                },
+                "category": {
+                    "type": "string"
+                },
+                "chunk_id": {
+                    "type": "integer"
+                },
+                "chunk_index": {
+                    "type": "integer"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "document_id": {
+                    "type": "integer"
+                },
+                "explain": {
+                    "description": "Filled when explain is requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ResultExplain"
+                        }
+                    ]
+                },
+                "matches": {
+                    "description": "Filled when highlighting is requested",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.MatchRange"
+                    }
+                },
+                "page_end": {
+                    "type": "integer"
+                },
+                "page_start": {
+                    "type": "integer"
+                },
+                "similarity": {
+                    "description": "from 0 to 1",
+                    "type": "number"
+                },
+                "snippets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Snippet"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "year": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Citation": {
+            "type": "object",
+            "properties": {
+                "chunk_index": {
+                    "type": "integer"
+                },
+                "document_id": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Conversation": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "document_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ConversationWithMessages": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "document_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChatMessage"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CreateConversationRequest": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "document_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Document": {
             "type": "object",
             "properties": {
-                "token": {
+                "authors": {
                     "type": "string"
                 },
-                "user": {
-                    "$ref": "#/definitions/models.User"
+                "category": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "file_path": {
+                    "type": "string"
+                },
+                "file_size": {
+                    "type": "integer"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "year": {
+                    "type": "integer"
                 }
             }
         },
-        "models.ChunkSearchResponse": {
+        "models.DocumentHitsResponse": {
+            "type": "object",
+            "properties": {
+                "document_id": {
+                    "type": "integer"
+                },
+                "query": {
+                    "type": "string"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChunkSearchResponse"
+                    }
+                }
+            }
+        },
+        "models.DocumentSearchResult": {
             "type": "object",
             "properties": {
                 "authors": {
                     "type": "string"
                 },
+                "candidate_hits": {
+                    "description": "Chunks of the document among the candidates fetched for grouping\n(limit × 5, at most 500); not a total, it grows with limit",
+                    "type": "integer"
+                },
                 "category": {
                     "type": "string"
                 },
+                "chunks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChunkSearchResponse"
+                    }
+                },
+                "document_id": {
+                    "type": "integer"
+                },
+                "score": {
+                    "description": "best chunk similarity",
+                    "type": "number"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "year": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.FacetCount": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "number of matching documents",
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.LoginRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.MatchRange": {
+            "type": "object",
+            "properties": {
+                "end": {
+                    "type": "integer"
+                },
+                "start": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ParsedQuery": {
+            "type": "object",
+            "properties": {
+                "exclude": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "filters": {
+                    "$ref": "#/definitions/models.SearchFilters"
+                },
+                "phrases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PassagePair": {
+            "type": "object",
+            "properties": {
                 "chunk_id": {
                     "type": "integer"
                 },
@@ -454,25 +1734,238 @@ const docTemplate = `{
                 "content": {
                     "type": "string"
                 },
-                "created_at": {
+                "input": {
                     "type": "string"
                 },
-                "document_id": {
+                "input_index": {
+                    "description": "passage number in the submitted text",
                     "type": "integer"
                 },
                 "similarity": {
-                    "description": "from 0 to 1",
                     "type": "number"
+                }
+            }
+        },
+        "models.PostMessageRequest": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "title": {
+                "content": {
                     "type": "string"
                 },
-                "year": {
+                "document_ids": {
+                    "description": "Override the conversation scope for this turn",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.PostMessageResponse": {
+            "type": "object",
+            "properties": {
+                "answer": {
+                    "$ref": "#/definitions/models.ChatMessage"
+                },
+                "question": {
+                    "$ref": "#/definitions/models.ChatMessage"
+                },
+                "sources": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChunkSearchResponse"
+                    }
+                }
+            }
+        },
+        "models.RegisterRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ResultExplain": {
+            "type": "object",
+            "properties": {
+                "cosine_distance": {
+                    "description": "pgvector \u003c=\u003e",
+                    "type": "number"
+                },
+                "rerank_score": {
+                    "description": "MMR score",
+                    "type": "number"
+                },
+                "trigram_score": {
+                    "description": "pg_trgm word_similarity",
+                    "type": "number"
+                }
+            }
+        },
+        "models.SearchExplain": {
+            "type": "object",
+            "properties": {
+                "filters": {
+                    "description": "conditions applied to the chunks",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "parsed_query": {
+                    "description": "Admins only",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ParsedQuery"
+                        }
+                    ]
+                },
+                "plan": {
+                    "type": "object"
+                },
+                "query": {
+                    "description": "ranked text after parsing",
+                    "type": "string"
+                },
+                "sql": {
+                    "type": "string"
+                },
+                "timings": {
+                    "$ref": "#/definitions/models.SearchTimings"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SearchFilters": {
+            "type": "object",
+            "properties": {
+                "author": {
+                    "description": "substring, case-insensitive",
+                    "type": "string"
+                },
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "document_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "exclude": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "formats": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "phrases": {
+                    "description": "Chunk content must contain every phrase and none of the exclusions",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "uploaded_from": {
+                    "type": "string"
+                },
+                "uploaded_to": {
+                    "type": "string"
+                },
+                "year_from": {
+                    "type": "integer"
+                },
+                "year_to": {
                     "type": "integer"
                 }
             }
         },
-        "models.Document": {
+        "models.SearchResponse": {
+            "type": "object",
+            "properties": {
+                "document_results": {
+                    "description": "Set instead of results with type=document",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SimilarDocument"
+                    }
+                },
+                "documents": {
+                    "description": "Set instead of results with group_by=document",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DocumentSearchResult"
+                    }
+                },
+                "explain": {
+                    "$ref": "#/definitions/models.SearchExplain"
+                },
+                "facets": {
+                    "description": "Document counts per value of each requested facet",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/models.FacetCount"
+                        }
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChunkSearchResponse"
+                    }
+                },
+                "total": {
+                    "description": "Approximate number of hits, when requested; total_relation is \"eq\"\nfor an exact count and \"gte\" when counting stopped at a cap",
+                    "type": "integer"
+                },
+                "total_relation": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SearchTimings": {
+            "type": "object",
+            "properties": {
+                "embedding_ms": {
+                    "type": "number"
+                },
+                "rerank_ms": {
+                    "type": "number"
+                },
+                "sql_ms": {
+                    "type": "number"
+                },
+                "total_ms": {
+                    "type": "number"
+                }
+            }
+        },
+        "models.SimilarDocument": {
             "type": "object",
             "properties": {
                 "authors": {
@@ -490,9 +1983,15 @@ const docTemplate = `{
                 "file_size": {
                     "type": "integer"
                 },
+                "format": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
+                "similarity": {
+                    "type": "number"
+                },
                 "title": {
                     "type": "string"
                 },
@@ -504,24 +2003,59 @@ const docTemplate = `{
                 }
             }
         },
-        "models.LoginRequest": {
+        "models.SimilarDocumentResult": {
             "type": "object",
             "properties": {
-                "email": {
+                "authors": {
                     "type": "string"
                 },
-                "password": {
+                "category": {
+                    "type": "string"
+                },
+                "document_id": {
+                    "type": "integer"
+                },
+                "passages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PassagePair"
+                    }
+                },
+                "score": {
+                    "type": "number"
+                },
+                "title": {
                     "type": "string"
+                },
+                "year": {
+                    "type": "integer"
                 }
             }
         },
-        "models.RegisterRequest": {
+        "models.SimilarSearchResponse": {
             "type": "object",
             "properties": {
-                "email": {
-                    "type": "string"
+                "input_passages": {
+                    "type": "integer"
                 },
-                "password": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SimilarDocumentResult"
+                    }
+                }
+            }
+        },
+        "models.Snippet": {
+            "type": "object",
+            "properties": {
+                "end": {
+                    "type": "integer"
+                },
+                "start": {
+                    "type": "integer"
+                },
+                "text": {
                     "type": "string"
                 }
             }
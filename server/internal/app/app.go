@@ -48,19 +48,53 @@ func (a *App) Run() error {
 
 	// Repositories
 	docRepo := repository.NewDocumentRepository(pool)
-	chunkRepo := repository.NewChunkRepository(pool)
+	chunkRepo := repository.NewChunkRepository(
+		pool, a.config.Database.VectorIndex, a.config.EmbeddingDimension,
+	)
 	userRepo := repository.NewUserRepository(pool)
+	chatRepo := repository.NewChatRepository(pool)
+	embeddingCacheRepo := repository.NewEmbeddingCacheRepository(pool)
 
 	// Service
-	embedderService := service.NewEmbedder(a.config)
+	embedder, err := service.NewEmbedder(a.config)
+	if err != nil {
+		return fmt.Errorf("failed to create embedder: %w", err)
+	}
+	if err := service.CheckEmbeddings(
+		context.Background(), a.config, chunkRepo, embedder,
+	); err != nil {
+		return fmt.Errorf("failed to check embeddings: %w", err)
+	}
+	if err := chunkRepo.CheckVectorIndex(); err != nil {
+		return fmt.Errorf("failed to check vector index: %w", err)
+	}
+	embeddings := service.NewEmbeddingCache(
+		embedder, a.config.EmbeddingDimension, a.config.EmbeddingCacheSize, embeddingCacheRepo,
+	)
+	if !a.config.Reembed.Enabled {
+		// While re-embedding the target model's entries are kept too
+		if err := embeddings.Invalidate(context.Background(), embedder.Model()); err != nil {
+			slog.Warn("embedding cache invalidation failed", "error", err)
+		}
+	}
 	docService := service.NewDocumentService(
-		a.config, docRepo, chunkRepo, embedderService,
+		a.config, docRepo, chunkRepo, embeddings,
 	)
 	searchService := service.NewSearchService(
-		a.config, chunkRepo, embedderService,
+		a.config, chunkRepo, docRepo, embeddings,
+	)
+
+	llm := service.NewOpenAIChat(a.config)
+	answerService := service.NewAnswerService(a.config, searchService, llm)
+	chatService := service.NewChatService(a.config, chatRepo, searchService, llm)
+	backfiller := service.NewBackfiller(
+		a.config, docRepo, chunkRepo, docService, embeddings,
 	)
 
-	handler := server.NewRouter(userRepo, docRepo, docService, searchService)
+	handler := server.NewRouter(
+		a.config, userRepo, docRepo, docService, searchService, answerService, chatService,
+		backfiller,
+	)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", a.config.Port),
@@ -70,6 +104,29 @@ func (a *App) Run() error {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go service.MonitorEmbedder(workerCtx, embedder)
+	go backfiller.Run(workerCtx)
+	if a.config.Reembed.Enabled {
+		reembedder, err := service.NewReembedder(
+			a.config, chunkRepo, embeddings, embeddingCacheRepo,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create re-embedder: %w", err)
+		}
+		go func() {
+			if err := reembedder.Run(workerCtx); err != nil {
+				if workerCtx.Err() == nil {
+					slog.Error("re-embedding stopped", "error", err)
+				}
+				return
+			}
+			// The cutover cleared the document search vectors
+			backfiller.Request()
+		}()
+	}
+
 	serverErr := make(chan error, 1)
 	go func() {
 		slog.Info("starting server", "addr", server.Addr)
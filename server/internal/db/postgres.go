@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -29,6 +30,20 @@ func NewPool(cfg config.DatabaseConfig) (*pgxpool.Pool, error) {
 	poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
 	poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
 	poolCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	// Custom settings are accepted before pg_trgm is loaded in the session
+	poolCfg.ConnConfig.RuntimeParams["pg_trgm.word_similarity_threshold"] =
+		strconv.FormatFloat(cfg.TextSimilarityThreshold, 'f', -1, 64)
+	// Read by the vector index migration
+	vi := cfg.VectorIndex
+	for name, value := range map[string]string{
+		"doc_archive.vector_index":         vi.Type,
+		"doc_archive.vector_storage":       vi.Storage,
+		"doc_archive.hnsw_m":               strconv.Itoa(vi.HNSWM),
+		"doc_archive.hnsw_ef_construction": strconv.Itoa(vi.HNSWEfConstruction),
+		"doc_archive.ivfflat_lists":        strconv.Itoa(vi.IVFFlatLists),
+	} {
+		poolCfg.ConnConfig.RuntimeParams[name] = value
+	}
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
 	if err != nil {
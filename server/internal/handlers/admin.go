@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/AndB0ndar/doc-archive/internal/middleware"
+	"github.com/AndB0ndar/doc-archive/internal/service"
+)
+
+type AdminHandler struct {
+	backfiller *service.Backfiller
+}
+
+func NewAdminHandler(backfiller *service.Backfiller) *AdminHandler {
+	return &AdminHandler{backfiller: backfiller}
+}
+
+// BackfillStatus возвращает ход дозаполнения эмбеддингов.
+// @Summary      Статус дозаполнения эмбеддингов
+// @Description  Возвращает ход текущего или последнего прохода фоновой задачи, которая
+// @Description  вычисляет недостающие эмбеддинги фрагментов и документов, и сколько
+// @Description  фрагментов и документов всё ещё без эмбеддинга. Только для администраторов.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  models.BackfillStatus
+// @Failure      401  {string}  string "Unauthorized"
+// @Failure      403  {string}  string "Forbidden"
+// @Failure      500  {string}  string "Failed to get backfill status"
+// @Security     BearerAuth
+// @Router       /admin/backfill [get]
+func (h *AdminHandler) BackfillStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	status, err := h.backfiller.Status(userID)
+	if err != nil {
+		handleBackfillError(w, err, "Failed to get backfill status")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		slog.Error("failed to encode backfill status", "error", err)
+	}
+}
+
+// StartBackfill запускает дозаполнение эмбеддингов.
+// @Summary      Запустить дозаполнение эмбеддингов
+// @Description  Запускает внеочередной проход фоновой задачи: документы без фрагментов
+// @Description  обрабатываются заново, фрагменты без эмбеддинга вычисляются пакетами
+// @Description  с ограничением скорости (BACKFILL_BATCH_SIZE, BACKFILL_RATE), затем
+// @Description  пересчитываются эмбеддинги затронутых документов. Ход прохода
+// @Description  возвращает GET /admin/backfill. Только для администраторов.
+// @Tags         admin
+// @Produce      json
+// @Success      202  {object}  models.BackfillStatus
+// @Failure      401  {string}  string "Unauthorized"
+// @Failure      403  {string}  string "Forbidden"
+// @Failure      409  {string}  string "Backfill is already running"
+// @Security     BearerAuth
+// @Router       /admin/backfill [post]
+func (h *AdminHandler) StartBackfill(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.backfiller.Start(userID); err != nil {
+		handleBackfillError(w, err, "Failed to start backfill")
+		return
+	}
+	status, err := h.backfiller.Status(userID)
+	if err != nil {
+		handleBackfillError(w, err, "Failed to get backfill status")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		slog.Error("failed to encode backfill status", "error", err)
+	}
+}
+
+func handleBackfillError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrBackfillRunning):
+		http.Error(w, "Backfill is already running", http.StatusConflict)
+	default:
+		slog.Error("backfill request failed", "error", err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
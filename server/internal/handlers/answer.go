@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/middleware"
+	"github.com/AndB0ndar/doc-archive/internal/models"
+	"github.com/AndB0ndar/doc-archive/internal/service"
+)
+
+type AnswerHandler struct {
+	answerService *service.AnswerService
+}
+
+func NewAnswerHandler(answerService *service.AnswerService) *AnswerHandler {
+	return &AnswerHandler{
+		answerService: answerService,
+	}
+}
+
+// Answer генерирует ответ на вопрос по найденным фрагментам документов.
+// @Summary      Ответ на вопрос (RAG)
+// @Description  Находит релевантные фрагменты и генерирует ответ с помощью LLM.
+// @Description  Ответ содержит ссылки на источники в формате [doc_id:chunk_index].
+// @Description  При stream=true или Accept: text/event-stream ответ передаётся через SSE,
+// @Description  при Accept: application/x-ndjson — строками JSON {"event", "data"}:
+// @Description  события sources, delta, затем summary (models.AnswerSummary) или error.
+// @Tags         search
+// @Produce      json
+// @Produce      text/event-stream
+// @Produce      application/x-ndjson
+// @Param        q query string true "Вопрос"
+// @Param        type query string false "Тип поиска фрагментов: semantic (по умолчанию) или text"
+// @Param        limit query int false "Количество фрагментов в контексте"
+// @Param        stream query bool false "Потоковая передача ответа (SSE)"
+// @Param        category query []string false "Категории (можно несколько)" collectionFormat(multi)
+// @Param        document_id query []int false "ID документов (можно несколько)" collectionFormat(multi)
+// @Param        mmr query bool false "Диверсификация фрагментов контекста (MMR)"
+// @Param        mmr_lambda query number false "Баланс релевантности и разнообразия от 0 до 1 (по умолчанию 0.5)"
+// @Success      200  {object}  models.AnswerResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Security     BearerAuth
+// @Router       /answer [get]
+func (h *AnswerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filters, err := parseSearchFilters(r.URL.Query())
+	if err != nil {
+		handleSearchError(w, err)
+		return
+	}
+
+	req := service.AnswerRequest{
+		Question: r.URL.Query().Get("q"),
+		Type:     r.URL.Query().Get("type"),
+		UserID:   userID,
+		Filters:  filters,
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil {
+			req.Limit = l
+		}
+	}
+
+	req.MMR = r.URL.Query().Get("mmr") == "true"
+	if lambdaStr := r.URL.Query().Get("mmr_lambda"); lambdaStr != "" {
+		lambda, err := strconv.ParseFloat(lambdaStr, 64)
+		if err != nil {
+			http.Error(w, "Invalid mmr_lambda", http.StatusBadRequest)
+			return
+		}
+		req.MMRLambda = &lambda
+	}
+
+	if stream, ndjson := streamFormat(r); stream {
+		h.stream(w, r, req, ndjson)
+		return
+	}
+
+	// Lift the server write timeout, the router's generation timeout
+	// bounds the request.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	answer, err := h.answerService.Answer(r.Context(), req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(answer); err != nil {
+		slog.Error("failed to encode answer", "error", err)
+	}
+}
+
+func (h *AnswerHandler) stream(
+	w http.ResponseWriter, r *http.Request, req service.AnswerRequest, ndjson bool,
+) {
+	// Retrieval errors are reported with a regular status code,
+	// the stream starts only once there is something to answer from.
+	start := time.Now()
+	sources, err := h.answerService.Retrieve(r.Context(), req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	retrieved := time.Now()
+	events := newEventWriter(w, ndjson)
+	if err := events.Event("sources", sources); err != nil {
+		return
+	}
+
+	answer, err := h.answerService.StreamAnswer(
+		r.Context(), req.Question, sources,
+		func(delta string) error {
+			return events.Event("delta", map[string]string{"content": delta})
+		},
+	)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		slog.Error("answer generation failed", "error", err)
+		events.Event("error", map[string]string{"error": "Answer generation failed"})
+		return
+	}
+	events.Event("summary", models.AnswerSummary{
+		AnswerResponse: *answer,
+		RetrievalMs:    milliseconds(retrieved.Sub(start)),
+		GenerationMs:   milliseconds(time.Since(retrieved)),
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (h *AnswerHandler) handleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrLLM) {
+		slog.Error("answer generation failed", "error", err)
+		http.Error(
+			w,
+			"Answer service unavailable",
+			http.StatusServiceUnavailable,
+		)
+		return
+	}
+	handleSearchError(w, err)
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AndB0ndar/doc-archive/internal/middleware"
+	"github.com/AndB0ndar/doc-archive/internal/models"
+	"github.com/AndB0ndar/doc-archive/internal/service"
+)
+
+type ChatHandler struct {
+	chatService *service.ChatService
+}
+
+func NewChatHandler(chatService *service.ChatService) *ChatHandler {
+	return &ChatHandler{chatService: chatService}
+}
+
+// CreateConversation создаёт новый диалог.
+// @Summary      Создать диалог
+// @Description  Создаёт диалог, опционально ограниченный документами или категориями.
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Param        request body models.CreateConversationRequest false "Параметры диалога"
+// @Success      201  {object}  models.Conversation
+// @Failure      400  {string}  string "Invalid request"
+// @Failure      401  {string}  string "Unauthorized"
+// @Failure      500  {string}  string "Failed to create conversation"
+// @Security     BearerAuth
+// @Router       /conversations [post]
+func (h *ChatHandler) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateConversationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	conv, err := h.chatService.CreateConversation(userID, req)
+	if err != nil {
+		slog.Error("failed to create conversation", "error", err)
+		http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(conv)
+}
+
+// ListConversations возвращает диалоги пользователя.
+// @Summary      Список диалогов
+// @Description  Возвращает диалоги, отсортированные по времени последнего сообщения.
+// @Tags         chat
+// @Produce      json
+// @Param        limit query int false "Максимальное количество диалогов (по умолчанию 20, макс 100)"
+// @Param        offset query int false "Смещение от начала списка (по умолчанию 0)"
+// @Success      200  {array}   models.Conversation
+// @Failure      401  {string}  string "Unauthorized"
+// @Failure      500  {string}  string "Failed to fetch conversations"
+// @Security     BearerAuth
+// @Router       /conversations [get]
+func (h *ChatHandler) ListConversations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	convs, err := h.chatService.ListConversations(userID, limit, offset)
+	if err != nil {
+		slog.Error("failed to list conversations", "error", err)
+		http.Error(w, "Failed to fetch conversations", http.StatusInternalServerError)
+		return
+	}
+	if convs == nil {
+		convs = []models.Conversation{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(convs); err != nil {
+		slog.Error("failed to encode conversations", "error", err)
+	}
+}
+
+// GetConversation возвращает диалог со всеми сообщениями.
+// @Summary      Получить диалог
+// @Description  Возвращает диалог и историю сообщений с цитатами.
+// @Tags         chat
+// @Produce      json
+// @Param        id path int true "ID диалога"
+// @Success      200  {object}  models.ConversationWithMessages
+// @Failure      400  {string}  string "Invalid conversation ID"
+// @Failure      401  {string}  string "Unauthorized"
+// @Failure      404  {string}  string "Conversation not found"
+// @Security     BearerAuth
+// @Router       /conversations/{id} [get]
+func (h *ChatHandler) GetConversation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	conv, err := h.chatService.GetConversation(id, userID)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conv)
+}
+
+// DeleteConversation удаляет диалог вместе с сообщениями.
+// @Summary      Удалить диалог
+// @Description  Удаляет диалог по ID.
+// @Tags         chat
+// @Param        id path int true "ID диалога"
+// @Success      204  "No Content"
+// @Failure      400  {string}  string "Invalid conversation ID"
+// @Failure      401  {string}  string "Unauthorized"
+// @Failure      404  {string}  string "Conversation not found"
+// @Security     BearerAuth
+// @Router       /conversations/{id} [delete]
+func (h *ChatHandler) DeleteConversation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.chatService.DeleteConversation(id, userID); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostMessage отправляет сообщение в диалог и возвращает ответ.
+// @Summary      Сообщение в диалог
+// @Description  Переформулирует вопрос с учётом истории, ищет фрагменты документов
+// @Description  пользователя и сохраняет ответ LLM вместе с цитатами.
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "ID диалога"
+// @Param        request body models.PostMessageRequest true "Сообщение"
+// @Success      201  {object}  models.PostMessageResponse
+// @Failure      400  {string}  string "Invalid request"
+// @Failure      401  {string}  string "Unauthorized"
+// @Failure      404  {string}  string "Conversation not found"
+// @Failure      503  {string}  string "Answer service unavailable"
+// @Security     BearerAuth
+// @Router       /conversations/{id}/messages [post]
+func (h *ChatHandler) PostMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.PostMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	// Lift the server write timeout, the router's generation timeout
+	// bounds the request.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	resp, err := h.chatService.PostMessage(r.Context(), id, userID, req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode chat response", "error", err)
+	}
+}
+
+func (h *ChatHandler) handleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrConversationNotFound):
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrEmptyMessage):
+		http.Error(w, "Message content is required", http.StatusBadRequest)
+	case errors.Is(err, service.ErrLLM):
+		slog.Error("chat generation failed", "error", err)
+		http.Error(
+			w,
+			"Answer service unavailable",
+			http.StatusServiceUnavailable,
+		)
+	default:
+		handleSearchError(w, err)
+	}
+}
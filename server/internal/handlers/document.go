@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
@@ -10,15 +11,21 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"github.com/AndB0ndar/doc-archive/internal/middleware"
+	"github.com/AndB0ndar/doc-archive/internal/models"
 	"github.com/AndB0ndar/doc-archive/internal/repository"
+	"github.com/AndB0ndar/doc-archive/internal/service"
 )
 
 type DocumentHandler struct {
-	repo *repository.DocumentRepository
+	repo          *repository.DocumentRepository
+	searchService *service.SearchService
 }
 
-func NewDocumentHandler(repo *repository.DocumentRepository) *DocumentHandler {
-	return &DocumentHandler{repo: repo}
+func NewDocumentHandler(
+	repo *repository.DocumentRepository,
+	searchService *service.SearchService,
+) *DocumentHandler {
+	return &DocumentHandler{repo: repo, searchService: searchService}
 }
 
 // GetDocument возвращает информацию о конкретном документе.
@@ -101,6 +108,140 @@ func (h *DocumentHandler) ListDocuments(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// SimilarDocuments возвращает документы, похожие на указанный.
+// @Summary      Похожие документы
+// @Description  Ищет ближайшие документы по среднему эмбеддингу фрагментов.
+// @Description  Сам документ в выдачу не входит.
+// @Tags         documents
+// @Produce      json
+// @Param        id path int true "ID документа"
+// @Param        limit query int false "Максимальное количество документов (по умолчанию 10, макс 100)"
+// @Success      200  {array}   models.SimilarDocument
+// @Failure      400  {string}  string "Invalid document ID"
+// @Failure      401  {string}  string "Unauthorized"
+// @Failure      404  {string}  string "Document not found"
+// @Failure      409  {string}  string "Document is not processed yet"
+// @Security     BearerAuth
+// @Router       /documents/{id}/similar [get]
+func (h *DocumentHandler) SimilarDocuments(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	if _, err := h.repo.GetByID(id, userID); err != nil {
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+
+	docs, err := h.repo.FindSimilar(id, userID, limit)
+	if errors.Is(err, repository.ErrNoEmbedding) {
+		http.Error(w, "Document is not processed yet", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to find similar documents", "id", id, "error", err)
+		http.Error(w, "Failed to fetch similar documents", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(docs); err != nil {
+		slog.Error("failed to encode similar documents", "error", err)
+	}
+}
+
+// SearchDocument ищет внутри одного документа.
+// @Summary      Поиск по документу
+// @Description  Полнотекстовый или семантический поиск по фрагментам одного документа.
+// @Description  Результаты упорядочены по страницам и содержат номера страниц,
+// @Description  позиции совпадений и фрагменты с подсветкой.
+// @Tags         documents
+// @Produce      json
+// @Param        id path int true "ID документа"
+// @Param        q query string true "Поисковый запрос (с поддержкой фраз и исключений)"
+// @Param        type query string false "Тип поиска: text (по умолчанию) или semantic"
+// @Param        limit query int false "Максимальное количество фрагментов (макс 100)"
+// @Param        min_score query number false "Минимальная оценка результата от 0 до 1"
+// @Param        fragment_size query int false "Длина фрагмента в символах (по умолчанию 200)"
+// @Param        fragments query int false "Количество фрагментов на результат (по умолчанию 3, макс 10)"
+// @Success      200  {object}  models.DocumentHitsResponse
+// @Failure      400  {string}  string "Invalid document ID"
+// @Failure      401  {string}  string "Unauthorized"
+// @Failure      404  {string}  string "Document not found"
+// @Failure      503  {string}  string "Embedding service unavailable"
+// @Security     BearerAuth
+// @Router       /documents/{id}/search [get]
+func (h *DocumentHandler) SearchDocument(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.repo.GetByID(id, userID); err != nil {
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+
+	query, err := service.ParseQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		handleSearchError(w, err)
+		return
+	}
+
+	req := service.SearchRequest{
+		Type:      r.URL.Query().Get("type"),
+		UserID:    userID,
+		Highlight: &service.HighlightOptions{},
+	}
+	query.Apply(&req)
+	req.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	if minScoreStr := r.URL.Query().Get("min_score"); minScoreStr != "" {
+		minScore, err := strconv.ParseFloat(minScoreStr, 64)
+		if err != nil {
+			http.Error(w, "Invalid min_score", http.StatusBadRequest)
+			return
+		}
+		req.MinScore = minScore
+	}
+	req.Highlight.FragmentSize, _ = strconv.Atoi(r.URL.Query().Get("fragment_size"))
+	req.Highlight.Fragments, _ = strconv.Atoi(r.URL.Query().Get("fragments"))
+
+	results, err := h.searchService.SearchDocument(r.Context(), req, id)
+	if err != nil {
+		handleSearchError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(models.DocumentHitsResponse{
+		DocumentID: id,
+		Query:      r.URL.Query().Get("q"),
+		Results:    results,
+	}); err != nil {
+		slog.Error("failed to encode document search results", "error", err)
+	}
+}
+
 // DeleteDocument удаляет документ и связанные файлы.
 // @Summary      Удалить документ
 // @Description  Удаляет документ по ID и его PDF-файл.
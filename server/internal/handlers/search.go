@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	"github.com/AndB0ndar/doc-archive/internal/middleware"
+	"github.com/AndB0ndar/doc-archive/internal/models"
 	"github.com/AndB0ndar/doc-archive/internal/service"
 )
 
@@ -23,13 +24,45 @@ func NewSearchHandler(searchService *service.SearchService) *SearchHandler {
 
 // Search выполняет поиск документов/чанков.
 // @Summary      Поиск документов
-// @Description  Полнотекстовый или семантический поиск по содержимому.
+// @Description  Полнотекстовый или семантический поиск по содержимому. С type=document
+// @Description  ранжируются сами документы (поле document_results) по вектору из названия,
+// @Description  авторов, аннотации (или первой страницы) и центроида фрагментов.
+// @Description  Запрос поддерживает язык фильтров: author:pike year:>=2010 category:go
+// @Description  "точная фраза" -исключение, диапазоны year:2010..2015 и uploaded:>=2024-01-01.
+// @Description  При Accept: application/x-ndjson или text/event-stream результаты передаются
+// @Description  по мере готовности этапов: candidates (до MMR), results, documents или document_results,
+// @Description  затем summary (models.SearchSummary) или error.
 // @Tags         search
 // @Produce      json
-// @Param        q query string true "Поисковый запрос"
-// @Param        type query string false "Тип поиска: text (по умолчанию) или vector"
+// @Produce      application/x-ndjson
+// @Produce      text/event-stream
+// @Param        q query string true "Поисковый запрос (с поддержкой языка фильтров)"
+// @Param        type query string false "Тип поиска: text (по умолчанию), vector (semantic) или document"
 // @Param        limit query int false "Максимальное количество результатов (макс 100)"
-// @Success      200  {array}   models.ChunkSearchResponse
+// @Param        min_score query number false "Минимальная оценка результата от 0 до 1"
+// @Param        category query []string false "Категории (можно несколько)" collectionFormat(multi)
+// @Param        year_from query int false "Год публикации от"
+// @Param        year_to query int false "Год публикации до"
+// @Param        author query string false "Подстрока в авторах"
+// @Param        document_id query []int false "ID документов (можно несколько)" collectionFormat(multi)
+// @Param        uploaded_from query string false "Загружен не раньше (YYYY-MM-DD или RFC 3339)"
+// @Param        uploaded_to query string false "Загружен не позже (YYYY-MM-DD или RFC 3339)"
+// @Param        format query []string false "Форматы файлов, например pdf" collectionFormat(multi)
+// @Param        highlight query bool false "Вернуть фрагменты с подсветкой (<mark>) и позиции совпадений"
+// @Param        fragment_size query int false "Длина фрагмента в символах (по умолчанию 200)"
+// @Param        fragments query int false "Количество фрагментов на результат (по умолчанию 3, макс 10)"
+// @Param        group_by query string false "Группировка: document — поле documents, один элемент на документ"
+// @Param        group_size query int false "Фрагментов на документ при группировке (по умолчанию 3, макс 10)"
+// @Param        mmr query bool false "Диверсификация семантической выдачи (maximal marginal relevance)"
+// @Param        mmr_lambda query number false "Баланс релевантности и разнообразия от 0 до 1 (по умолчанию 0.5)"
+// @Param        cursor query string false "Курсор следующей страницы (next_cursor из предыдущего ответа)"
+// @Param        total query bool false "Посчитать приблизительное количество совпадений"
+// @Param        facets query []string false "Фасеты: category, year, author, format" collectionFormat(csv)
+// @Param        facet_size query int false "Значений на фасет (по умолчанию 10, макс 100)"
+// @Param        explain query bool false "Исходные оценки, применённые фильтры и время этапов; администраторам также разбор запроса и план SQL"
+// @Param        ef_search query int false "hnsw.ef_search для семантического поиска (1..1000, по умолчанию по limit)"
+// @Param        probes query int false "ivfflat.probes для семантического поиска (1..1000, по умолчанию IVFFLAT_PROBES)"
+// @Success      200  {object}  models.SearchResponse
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
 // @Security     BearerAuth
@@ -41,20 +74,77 @@ func (h *SearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filters, err := parseSearchFilters(r.URL.Query())
+	if err != nil {
+		handleSearchError(w, err)
+		return
+	}
+
+	query, err := service.ParseQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		handleSearchError(w, err)
+		return
+	}
+
 	req := service.SearchRequest{
-		Query:  r.URL.Query().Get("q"),
-		Type:   r.URL.Query().Get("type"),
-		UserID: userID,
+		Type:      r.URL.Query().Get("type"),
+		UserID:    userID,
+		Filters:   filters,
+		GroupBy:   r.URL.Query().Get("group_by"),
+		Cursor:    r.URL.Query().Get("cursor"),
+		WithTotal: r.URL.Query().Get("total") == "true",
+		Explain:   r.URL.Query().Get("explain") == "true",
 	}
+	query.Apply(&req)
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil {
 			req.Limit = l
 		}
 	}
+	req.GroupSize, _ = strconv.Atoi(r.URL.Query().Get("group_size"))
+	req.Facets = multiValue(r.URL.Query(), "facets")
+	req.FacetSize, _ = strconv.Atoi(r.URL.Query().Get("facet_size"))
+	req.MMR = r.URL.Query().Get("mmr") == "true"
+	for name, dest := range map[string]*int{"ef_search": &req.EfSearch, "probes": &req.Probes} {
+		if v := r.URL.Query().Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dest = n
+		}
+	}
+	if lambdaStr := r.URL.Query().Get("mmr_lambda"); lambdaStr != "" {
+		lambda, err := strconv.ParseFloat(lambdaStr, 64)
+		if err != nil {
+			http.Error(w, "Invalid mmr_lambda", http.StatusBadRequest)
+			return
+		}
+		req.MMRLambda = &lambda
+	}
+	if minScoreStr := r.URL.Query().Get("min_score"); minScoreStr != "" {
+		minScore, err := strconv.ParseFloat(minScoreStr, 64)
+		if err != nil {
+			http.Error(w, "Invalid min_score", http.StatusBadRequest)
+			return
+		}
+		req.MinScore = minScore
+	}
+	if r.URL.Query().Get("highlight") == "true" {
+		req.Highlight = &service.HighlightOptions{}
+		req.Highlight.FragmentSize, _ = strconv.Atoi(r.URL.Query().Get("fragment_size"))
+		req.Highlight.Fragments, _ = strconv.Atoi(r.URL.Query().Get("fragments"))
+	}
+
+	if stream, ndjson := streamFormat(r); stream {
+		h.stream(w, r, req, ndjson)
+		return
+	}
 
-	results, err := h.searchService.Search(req)
+	results, err := h.searchService.SearchPage(r.Context(), req)
 	if err != nil {
-		h.handleError(w, err)
+		handleSearchError(w, err)
 		return
 	}
 
@@ -65,14 +155,85 @@ func (h *SearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *SearchHandler) handleError(w http.ResponseWriter, err error) {
+// stream sends the results of every search stage as it completes,
+// then the documents (with group_by or type=document) and a summary event.
+func (h *SearchHandler) stream(
+	w http.ResponseWriter, r *http.Request, req service.SearchRequest, ndjson bool,
+) {
+	events := newEventWriter(w, ndjson)
+	req.OnStage = func(stage string, results []models.ChunkSearchResponse) error {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		return events.Event(stage, results)
+	}
+
+	resp, err := h.searchService.SearchPage(r.Context(), req)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		if !events.Started() {
+			handleSearchError(w, err)
+			return
+		}
+		slog.Error("streamed search failed", "error", err)
+		events.Event("error", map[string]string{"error": "Search failed"})
+		return
+	}
+
+	count := len(resp.Results)
+	if resp.Documents != nil {
+		count = len(resp.Documents)
+		if err := events.Event("documents", resp.Documents); err != nil {
+			return
+		}
+	}
+	if resp.DocumentResults != nil {
+		count = len(resp.DocumentResults)
+		if err := events.Event("document_results", resp.DocumentResults); err != nil {
+			return
+		}
+	}
+	events.Event("summary", models.SearchSummary{
+		Count:         count,
+		NextCursor:    resp.NextCursor,
+		Total:         resp.Total,
+		TotalRelation: resp.TotalRelation,
+		Facets:        resp.Facets,
+		Explain:       resp.Explain,
+	})
+}
+
+func handleSearchError(w http.ResponseWriter, err error) {
 	switch {
 	case errors.Is(err, service.ErrEmptyQuery):
 		http.Error(w, "Missing search query (q)", http.StatusBadRequest)
+	case errors.Is(err, service.ErrNoQueryText):
+		http.Error(
+			w,
+			"Search query needs text to search for besides qualifiers and exclusions",
+			http.StatusBadRequest,
+		)
+	case errors.Is(err, service.ErrQuerySyntax):
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidFilter),
+		errors.Is(err, service.ErrInvalidGroupBy),
+		errors.Is(err, service.ErrInvalidMMR),
+		errors.Is(err, service.ErrInvalidCursor),
+		errors.Is(err, service.ErrInvalidFacet),
+		errors.Is(err, service.ErrInvalidScan):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidDocumentSearchType):
+		http.Error(
+			w,
+			"Invalid search type. Use 'text' or 'semantic' within a document",
+			http.StatusBadRequest,
+		)
 	case errors.Is(err, service.ErrInvalidType):
 		http.Error(
 			w,
-			"Invalid search type. Use 'text' or 'semantic'",
+			"Invalid search type. Use 'text', 'semantic' or 'document'",
 			http.StatusBadRequest,
 		)
 	case errors.Is(err, service.ErrEmbedding):
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+	"github.com/AndB0ndar/doc-archive/internal/service"
+)
+
+// parseSearchFilters reads the document metadata filters of /search.
+// Multi-valued parameters may be repeated or comma-separated.
+func parseSearchFilters(q url.Values) (models.SearchFilters, error) {
+	var f models.SearchFilters
+	var err error
+
+	f.Categories = multiValue(q, "category")
+	f.Formats = multiValue(q, "format")
+	f.Author = strings.TrimSpace(q.Get("author"))
+
+	for _, v := range multiValue(q, "document_id") {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return f, fmt.Errorf("%w: invalid document_id %q", service.ErrInvalidFilter, v)
+		}
+		f.DocumentIDs = append(f.DocumentIDs, id)
+	}
+
+	if f.YearFrom, err = intParam(q, "year_from"); err != nil {
+		return f, err
+	}
+	if f.YearTo, err = intParam(q, "year_to"); err != nil {
+		return f, err
+	}
+	if f.UploadedFrom, err = timeParam(q, "uploaded_from", false); err != nil {
+		return f, err
+	}
+	if f.UploadedTo, err = timeParam(q, "uploaded_to", true); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+func multiValue(q url.Values, key string) []string {
+	var values []string
+	for _, raw := range q[key] {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+func intParam(q url.Values, key string) (*int, error) {
+	v := strings.TrimSpace(q.Get(key))
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s %q", service.ErrInvalidFilter, key, v)
+	}
+	return &n, nil
+}
+
+// timeParam accepts RFC 3339 timestamps and plain dates. A plain date used
+// as an upper bound covers the whole day.
+func timeParam(q url.Values, key string, endOfDay bool) (*time.Time, error) {
+	v := strings.TrimSpace(q.Get(key))
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: invalid %s %q, use YYYY-MM-DD or RFC 3339",
+			service.ErrInvalidFilter, key, v,
+		)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Microsecond)
+	}
+	return &t, nil
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/middleware"
+	"github.com/AndB0ndar/doc-archive/internal/service"
+)
+
+type similarTextRequest struct {
+	Text string `json:"text"`
+}
+
+// Similar ищет документы архива, пересекающиеся с текстом или файлом.
+// @Summary      Поиск по тексту или файлу
+// @Description  Разбивает текст (JSON {"text": ...} или поле формы text) либо PDF-файл
+// @Description  на фрагменты тем же конвейером, что и загрузку, и ранжирует документы
+// @Description  по близости их фрагментов. Для каждого документа возвращаются пары
+// @Description  «фрагмент запроса — фрагмент документа».
+// @Tags         search
+// @Accept       json
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file formData file false "PDF-файл"
+// @Param        text formData string false "Текст"
+// @Param        limit query int false "Максимальное количество документов (макс 100)"
+// @Param        pairs query int false "Пар фрагментов на документ (по умолчанию 3, макс 10)"
+// @Param        category query []string false "Категории (можно несколько)" collectionFormat(multi)
+// @Param        document_id query []int false "ID документов (можно несколько)" collectionFormat(multi)
+// @Success      200  {object}  models.SimilarSearchResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Security     BearerAuth
+// @Router       /search/similar [post]
+func (h *SearchHandler) Similar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filters, err := parseSearchFilters(r.URL.Query())
+	if err != nil {
+		handleSearchError(w, err)
+		return
+	}
+
+	// Request size limit (50 MB), as for uploads
+	r.Body = http.MaxBytesReader(w, r.Body, 50<<20)
+
+	text, err := readSimilarInput(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := service.SimilarRequest{
+		Text:    text,
+		UserID:  userID,
+		Filters: filters,
+	}
+	req.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	req.Pairs, _ = strconv.Atoi(r.URL.Query().Get("pairs"))
+
+	// Every input passage is embedded, long inputs outlast the write timeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	resp, err := h.searchService.SearchSimilar(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handleSearchError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode similar search results", "error", err)
+	}
+}
+
+// readSimilarInput returns the submitted text: a PDF or text field of a
+// multipart form, or the text of a JSON body.
+func readSimilarInput(r *http.Request) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		var body similarTextRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return "", errors.New("Invalid request")
+		}
+		return body.Text, nil
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return "", errors.New("Failed to parse form")
+	}
+	file, _, err := r.FormFile("file")
+	if errors.Is(err, http.ErrMissingFile) {
+		return r.FormValue("text"), nil
+	}
+	if err != nil {
+		return "", errors.New("Failed to read file")
+	}
+	defer file.Close()
+
+	text, err := service.ExtractTextFrom(file)
+	if err != nil {
+		slog.Error("failed to extract text from submitted PDF", "error", err)
+		return "", errors.New("File is not a readable PDF")
+	}
+	return text, nil
+}
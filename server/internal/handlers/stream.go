@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// eventWriter streams named JSON events and flushes every event to the
+// client, as Server-Sent Events or as newline-delimited JSON objects
+// {"event": ..., "data": ...}. Headers are sent with the first event, so
+// errors before it can still use a regular status code.
+type eventWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	ndjson  bool
+	started bool
+}
+
+func newEventWriter(w http.ResponseWriter, ndjson bool) *eventWriter {
+	return &eventWriter{w: w, rc: http.NewResponseController(w), ndjson: ndjson}
+}
+
+func (s *eventWriter) start() {
+	s.started = true
+	// Streams outlive the server write timeout. Streamed searches run
+	// without a router timeout until they finish or the client leaves,
+	// answers keep the generation timeout.
+	_ = s.rc.SetWriteDeadline(time.Time{})
+
+	if s.ndjson {
+		s.w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Connection", "keep-alive")
+	}
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.Header().Set("X-Accel-Buffering", "no")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// Started reports whether the response status has been sent.
+func (s *eventWriter) Started() bool {
+	return s.started
+}
+
+func (s *eventWriter) Event(event string, data any) error {
+	if !s.started {
+		s.start()
+	}
+	var err error
+	if s.ndjson {
+		err = json.NewEncoder(s.w).Encode(struct {
+			Event string `json:"event"`
+			Data  any    `json:"data"`
+		}{event, data})
+	} else {
+		var payload []byte
+		if payload, err = json.Marshal(data); err != nil {
+			return fmt.Errorf("marshal event: %w", err)
+		}
+		_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+	}
+	if err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// IsStream tells whether the client asked for a streamed response.
+func IsStream(r *http.Request) bool {
+	stream, _ := streamFormat(r)
+	return stream
+}
+
+// streamFormat tells whether the client asked for a stream and whether
+// it should be NDJSON rather than Server-Sent Events.
+func streamFormat(r *http.Request) (stream, ndjson bool) {
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, "application/x-ndjson") {
+		return true, true
+	}
+	if r.URL.Query().Get("stream") == "true" {
+		return true, false
+	}
+	return strings.Contains(accept, "text/event-stream"), false
+}
@@ -0,0 +1,22 @@
+package models
+
+type Citation struct {
+	DocumentID int    `json:"document_id"`
+	ChunkIndex int    `json:"chunk_index"`
+	Title      string `json:"title"`
+}
+
+type AnswerResponse struct {
+	Question  string                `json:"question"`
+	Answer    string                `json:"answer"`
+	Citations []Citation            `json:"citations"`
+	Sources   []ChunkSearchResponse `json:"sources"`
+}
+
+// AnswerSummary is the last event of a streamed answer: the full answer
+// with its sources and the time spent on each stage.
+type AnswerSummary struct {
+	AnswerResponse
+	RetrievalMs  float64 `json:"retrieval_ms"`
+	GenerationMs float64 `json:"generation_ms"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+type Conversation struct {
+	ID          int64     `json:"id"`
+	Title       string    `json:"title"`
+	DocumentIDs []int     `json:"document_ids"`
+	Categories  []string  `json:"categories"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UserID      int       `json:"-"`
+}
+
+type ChatMessage struct {
+	ID             int64      `json:"id"`
+	ConversationID int64      `json:"conversation_id"`
+	Role           string     `json:"role"` // user or assistant
+	Content        string     `json:"content"`
+	SearchQuery    *string    `json:"search_query,omitempty"`
+	Citations      []Citation `json:"citations"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type ConversationWithMessages struct {
+	Conversation
+	Messages []ChatMessage `json:"messages"`
+}
+
+type CreateConversationRequest struct {
+	Title       string   `json:"title"`
+	DocumentIDs []int    `json:"document_ids"`
+	Categories  []string `json:"categories"`
+}
+
+type PostMessageRequest struct {
+	Content string `json:"content"`
+	// Override the conversation scope for this turn
+	DocumentIDs []int    `json:"document_ids,omitempty"`
+	Categories  []string `json:"categories,omitempty"`
+}
+
+type PostMessageResponse struct {
+	Question ChatMessage           `json:"question"`
+	Answer   ChatMessage           `json:"answer"`
+	Sources  []ChunkSearchResponse `json:"sources"`
+}
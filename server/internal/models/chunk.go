@@ -10,7 +10,11 @@ type Chunk struct {
 	ChunkIndex int       `json:"chunk_index"`
 	Content    string    `json:"content"`
 	Embedding  []float32 `json:"-"`
+	PageStart  *int      `json:"page_start,omitempty"` // first PDF page of the chunk
+	PageEnd    *int      `json:"page_end,omitempty"`
 	CreatedAt  time.Time `json:"created_at"`
+	// Embedder model that produced Embedding
+	EmbeddingModel string `json:"-"`
 }
 
 type ChunkSearchResponse struct {
@@ -20,8 +24,30 @@ type ChunkSearchResponse struct {
 	Content    string    `json:"content"`
 	CreatedAt  time.Time `json:"created_at"`
 	Similarity float64   `json:"similarity"` // from 0 to 1
+	PageStart  *int      `json:"page_start,omitempty"`
+	PageEnd    *int      `json:"page_end,omitempty"`
 	Title      string    `json:"title"`
 	Authors    *string   `json:"authors,omitempty"`
 	Year       *int      `json:"year,omitempty"`
 	Category   *string   `json:"category,omitempty"`
+	Embedding  []float32 `json:"-"` // loaded only for re-ranking
+	// Filled when highlighting is requested
+	Matches  []MatchRange `json:"matches,omitempty"`
+	Snippets []Snippet    `json:"snippets,omitempty"`
+	// Filled when explain is requested
+	Explain *ResultExplain `json:"explain,omitempty"`
+}
+
+// MatchRange is a matched part of the content, [Start, End) in characters.
+type MatchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Snippet is an HTML-escaped fragment of the content with matches wrapped
+// in <mark> tags; Start and End locate it in the content.
+type Snippet struct {
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
 }
@@ -12,6 +12,15 @@ type Document struct {
 	Category  *string   `json:"category,omitempty"`
 	FilePath  string    `json:"file_path"`
 	FileSize  int64     `json:"file_size"`
+	Format    string    `json:"format"`
 	CreatedAt time.Time `json:"created_at"`
 	UserID    int       `json:"user_id"`
 }
+
+// SimilarDocument is a document ranked by the cosine similarity of its
+// vector to another document's (/documents/{id}/similar) or to the query
+// (type=document search).
+type SimilarDocument struct {
+	Document
+	Similarity float64 `json:"similarity"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// EmbeddingModelStats counts the chunks embedded by a model.
+type EmbeddingModelStats struct {
+	Model     string `json:"model"`
+	Dimension int    `json:"dimension"`
+	Chunks    int    `json:"chunks"`
+}
+
+// BackfillStatus reports the last or running embedding backfill and what
+// is still missing.
+type BackfillStatus struct {
+	Running    bool       `json:"running"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Documents without chunks extracted again
+	DocumentsReprocessed int `json:"documents_reprocessed"`
+	DocumentsFailed      int `json:"documents_failed"`
+	ChunksEmbedded       int `json:"chunks_embedded"`
+	ChunksFailed         int `json:"chunks_failed"`
+	// Document vectors recomputed from their chunks, title and abstract
+	DocumentsUpdated int    `json:"documents_updated"`
+	LastError        string `json:"last_error,omitempty"`
+	// Counted when the status is requested
+	MissingChunks    int `json:"missing_chunks"`
+	MissingDocuments int `json:"missing_documents"`
+}
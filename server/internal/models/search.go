@@ -0,0 +1,141 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type SearchFilters struct {
+	DocumentIDs  []int      `json:"document_ids,omitempty"`
+	Categories   []string   `json:"categories,omitempty"`
+	YearFrom     *int       `json:"year_from,omitempty"`
+	YearTo       *int       `json:"year_to,omitempty"`
+	Author       string     `json:"author,omitempty"` // substring, case-insensitive
+	UploadedFrom *time.Time `json:"uploaded_from,omitempty"`
+	UploadedTo   *time.Time `json:"uploaded_to,omitempty"`
+	Formats      []string   `json:"formats,omitempty"`
+	// Chunk content must contain every phrase and none of the exclusions
+	Phrases []string `json:"phrases,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// DocumentSearchResult is a search hit aggregated over a document's chunks.
+type DocumentSearchResult struct {
+	DocumentID int     `json:"document_id"`
+	Title      string  `json:"title"`
+	Authors    *string `json:"authors,omitempty"`
+	Year       *int    `json:"year,omitempty"`
+	Category   *string `json:"category,omitempty"`
+	Score      float64 `json:"score"` // best chunk similarity
+	// Chunks of the document among the candidates fetched for grouping
+	// (limit × 5, at most 500); not a total, it grows with limit
+	CandidateHits int                   `json:"candidate_hits"`
+	Chunks        []ChunkSearchResponse `json:"chunks"`
+}
+
+// SearchCursor is the keyset position of the last result of a page.
+type SearchCursor struct {
+	Score   float64 `json:"s"`
+	ChunkID int64   `json:"id"`
+	Query   uint64  `json:"q"` // fingerprint of the request the cursor belongs to
+}
+
+type SearchResponse struct {
+	Results []ChunkSearchResponse `json:"results"`
+	// Set instead of results with group_by=document
+	Documents []DocumentSearchResult `json:"documents,omitempty"`
+	// Set instead of results with type=document
+	DocumentResults []SimilarDocument `json:"document_results,omitempty"`
+	NextCursor      *string           `json:"next_cursor"`
+	// Approximate number of hits, when requested; total_relation is "eq"
+	// for an exact count and "gte" when counting stopped at a cap
+	Total         *int   `json:"total,omitempty"`
+	TotalRelation string `json:"total_relation,omitempty"`
+	// Document counts per value of each requested facet
+	Facets  map[string][]FacetCount `json:"facets,omitempty"`
+	Explain *SearchExplain          `json:"explain,omitempty"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"` // number of matching documents
+}
+
+// PassagePair is an input passage and the archived chunk closest to it.
+type PassagePair struct {
+	InputIndex int     `json:"input_index"` // passage number in the submitted text
+	Input      string  `json:"input"`
+	ChunkID    int64   `json:"chunk_id"`
+	ChunkIndex int     `json:"chunk_index"`
+	Content    string  `json:"content"`
+	Similarity float64 `json:"similarity"`
+}
+
+// SimilarDocumentResult is an archived document overlapping the submitted
+// text. Score is the mean over input passages of the best similarity.
+type SimilarDocumentResult struct {
+	DocumentID int           `json:"document_id"`
+	Title      string        `json:"title"`
+	Authors    *string       `json:"authors,omitempty"`
+	Year       *int          `json:"year,omitempty"`
+	Category   *string       `json:"category,omitempty"`
+	Score      float64       `json:"score"`
+	Passages   []PassagePair `json:"passages"`
+}
+
+type SimilarSearchResponse struct {
+	InputPassages int                     `json:"input_passages"`
+	Results       []SimilarDocumentResult `json:"results"`
+}
+
+// DocumentHitsResponse lists the hits inside one document in page order.
+type DocumentHitsResponse struct {
+	DocumentID int                   `json:"document_id"`
+	Query      string                `json:"query"`
+	Results    []ChunkSearchResponse `json:"results"`
+}
+
+// ResultExplain holds the raw scores behind a result's similarity.
+type ResultExplain struct {
+	TrigramScore   *float64 `json:"trigram_score,omitempty"`   // pg_trgm word_similarity
+	CosineDistance *float64 `json:"cosine_distance,omitempty"` // pgvector <=>
+	RerankScore    *float64 `json:"rerank_score,omitempty"`    // MMR score
+}
+
+// SearchExplain describes how a /search response was produced.
+type SearchExplain struct {
+	Type    string        `json:"type"`
+	Query   string        `json:"query"`   // ranked text after parsing
+	Filters []string      `json:"filters"` // conditions applied to the chunks
+	Timings SearchTimings `json:"timings"`
+	// Admins only
+	ParsedQuery *ParsedQuery    `json:"parsed_query,omitempty"`
+	SQL         string          `json:"sql,omitempty"`
+	Plan        json.RawMessage `json:"plan,omitempty" swaggertype:"object"`
+}
+
+// SearchTimings are in milliseconds.
+type SearchTimings struct {
+	Embedding float64 `json:"embedding_ms"`
+	SQL       float64 `json:"sql_ms"`
+	Rerank    float64 `json:"rerank_ms"`
+	Total     float64 `json:"total_ms"`
+}
+
+type ParsedQuery struct {
+	Text    string        `json:"text"`
+	Phrases []string      `json:"phrases,omitempty"`
+	Exclude []string      `json:"exclude,omitempty"`
+	Filters SearchFilters `json:"filters"`
+}
+
+// SearchSummary is the last event of a streamed /search: the response
+// without the results already sent.
+type SearchSummary struct {
+	Count         int                     `json:"count"`
+	NextCursor    *string                 `json:"next_cursor"`
+	Total         *int                    `json:"total,omitempty"`
+	TotalRelation string                  `json:"total_relation,omitempty"`
+	Facets        map[string][]FacetCount `json:"facets,omitempty"`
+	Explain       *SearchExplain          `json:"explain,omitempty"`
+}
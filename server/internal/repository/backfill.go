@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+// MissingEmbeddings returns up to limit chunks without an embedding whose
+// id is above afterID, in id order.
+func (r *ChunkRepository) MissingEmbeddings(afterID int64, limit int) ([]models.Chunk, error) {
+	rows, err := r.db.Query(r.ctx, `
+		SELECT id, document_id, chunk_index, content
+		FROM chunks
+		WHERE embedding IS NULL AND id > $1
+		ORDER BY id
+		LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get chunks without embedding: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []models.Chunk
+	for rows.Next() {
+		var c models.Chunk
+		if err := rows.Scan(&c.ID, &c.DocumentID, &c.ChunkIndex, &c.Content); err != nil {
+			return nil, fmt.Errorf("scan chunk: %w", err)
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// SetEmbedding stores the embedding of a chunk that has none. It reports
+// false when the chunk is gone or was embedded meanwhile.
+func (r *ChunkRepository) SetEmbedding(id int64, embedding []float32, model string) (bool, error) {
+	tag, err := r.db.Exec(r.ctx, `
+		UPDATE chunks
+		SET embedding = $2, embedding_model = nullif($3, ''), embedding_dim = $4
+		WHERE id = $1 AND embedding IS NULL
+	`, id, pgvector.NewVector(embedding), model, len(embedding))
+	if err != nil {
+		return false, fmt.Errorf("set chunk embedding: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// CountMissingEmbeddings counts the chunks without an embedding and the
+// documents without an embedding or search vector.
+func (r *ChunkRepository) CountMissingEmbeddings() (chunks, documents int, err error) {
+	err = r.db.QueryRow(r.ctx, `
+		SELECT
+			(SELECT count(*) FROM chunks WHERE embedding IS NULL),
+			(SELECT count(*) FROM documents
+			 WHERE embedding IS NULL OR search_embedding IS NULL)
+	`).Scan(&chunks, &documents)
+	if err != nil {
+		return 0, 0, fmt.Errorf("count missing embeddings: %w", err)
+	}
+	return chunks, documents, nil
+}
+
+// WithoutChunks returns up to limit documents created before the cutoff
+// that have no chunks, such as those whose processing failed, with id
+// above afterID in id order. Documents already reprocessed are left out.
+func (r *DocumentRepository) WithoutChunks(
+	createdBefore time.Time, afterID, limit int,
+) ([]models.Document, error) {
+	rows, err := r.db.Query(r.ctx, `
+		SELECT id, title, file_path, user_id
+		FROM documents d
+		WHERE d.created_at < $1 AND d.id > $2 AND d.reprocessed_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM chunks c WHERE c.document_id = d.id)
+		ORDER BY d.id
+		LIMIT $3
+	`, createdBefore, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get documents without chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []models.Document
+	for rows.Next() {
+		var d models.Document
+		if err := rows.Scan(&d.ID, &d.Title, &d.FilePath, &d.UserID); err != nil {
+			return nil, fmt.Errorf("scan document: %w", err)
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// MarkReprocessed records that the backfill chunked the document again,
+// so WithoutChunks does not return it whatever the outcome.
+func (r *DocumentRepository) MarkReprocessed(id int) error {
+	_, err := r.db.Exec(r.ctx, `UPDATE documents SET reprocessed_at = now() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("mark document reprocessed: %w", err)
+	}
+	return nil
+}
+
+// MissingEmbedding returns the ids of documents without an embedding that
+// have embedded chunks to average.
+func (r *DocumentRepository) MissingEmbedding() ([]int, error) {
+	rows, err := r.db.Query(r.ctx, `
+		SELECT d.id
+		FROM documents d
+		WHERE d.embedding IS NULL AND EXISTS (
+			SELECT 1 FROM chunks c
+			WHERE c.document_id = d.id AND c.embedding IS NOT NULL
+		)
+		ORDER BY d.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("get documents without embedding: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan document id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MissingSearchEmbedding returns the ids of documents created before the
+// cutoff that have no search vector.
+func (r *DocumentRepository) MissingSearchEmbedding(createdBefore time.Time) ([]int, error) {
+	rows, err := r.db.Query(r.ctx, `
+		SELECT id FROM documents
+		WHERE search_embedding IS NULL AND created_at < $1
+		ORDER BY id
+	`, createdBefore)
+	if err != nil {
+		return nil, fmt.Errorf("get documents without search embedding: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan document id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+type ChatRepository struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func NewChatRepository(db *pgxpool.Pool) *ChatRepository {
+	return &ChatRepository{
+		ctx: context.Background(),
+		db:  db,
+	}
+}
+
+func (r *ChatRepository) CreateConversation(conv *models.Conversation) (int64, error) {
+	query := `
+		INSERT INTO conversations (user_id, title, document_ids, categories)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`
+	err := r.db.QueryRow(r.ctx, query,
+		conv.UserID, conv.Title, conv.DocumentIDs, conv.Categories,
+	).Scan(&conv.ID, &conv.CreatedAt, &conv.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("insert conversation: %w", err)
+	}
+	return conv.ID, nil
+}
+
+func (r *ChatRepository) GetConversation(
+	id int64, userID int,
+) (*models.Conversation, error) {
+	query := `
+		SELECT id, title, document_ids, categories, created_at, updated_at
+		FROM conversations WHERE id = $1 AND user_id = $2
+	`
+	conv := models.Conversation{UserID: userID}
+	err := r.db.QueryRow(r.ctx, query, id, userID).Scan(
+		&conv.ID, &conv.Title, &conv.DocumentIDs, &conv.Categories,
+		&conv.CreatedAt, &conv.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation: %w", err)
+	}
+	return &conv, nil
+}
+
+func (r *ChatRepository) ListConversations(
+	userID, limit, offset int,
+) ([]models.Conversation, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	query := `
+		SELECT id, title, document_ids, categories, created_at, updated_at
+		FROM conversations WHERE user_id = $1
+		ORDER BY updated_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.Query(r.ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var convs []models.Conversation
+	for rows.Next() {
+		c := models.Conversation{UserID: userID}
+		if err := rows.Scan(
+			&c.ID, &c.Title, &c.DocumentIDs, &c.Categories,
+			&c.CreatedAt, &c.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan conversation: %w", err)
+		}
+		convs = append(convs, c)
+	}
+	return convs, nil
+}
+
+func (r *ChatRepository) DeleteConversation(id int64, userID int) error {
+	query := `DELETE FROM conversations WHERE id = $1 AND user_id = $2`
+	cmdTag, err := r.db.Exec(r.ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete conversation: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("conversation with id %d not found", id)
+	}
+	return nil
+}
+
+// AddExchange stores a question and its reply in order and bumps the
+// conversation updated_at, all in one transaction, so a conversation
+// never ends with an unanswered question.
+func (r *ChatRepository) AddExchange(question, reply *models.ChatMessage) error {
+	tx, err := r.db.Begin(r.ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(r.ctx)
+
+	query := `
+		INSERT INTO messages (conversation_id, role, content, search_query, citations)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	for _, msg := range []*models.ChatMessage{question, reply} {
+		if msg.Citations == nil {
+			msg.Citations = []models.Citation{}
+		}
+		err = tx.QueryRow(r.ctx, query,
+			msg.ConversationID, msg.Role, msg.Content, msg.SearchQuery, msg.Citations,
+		).Scan(&msg.ID, &msg.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("insert %s message: %w", msg.Role, err)
+		}
+	}
+
+	_, err = tx.Exec(r.ctx,
+		`UPDATE conversations SET updated_at = NOW() WHERE id = $1`,
+		question.ConversationID,
+	)
+	if err != nil {
+		return fmt.Errorf("touch conversation: %w", err)
+	}
+
+	if err := tx.Commit(r.ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// ListMessages returns the last limit messages of the conversation
+// in chronological order; limit <= 0 returns all of them.
+func (r *ChatRepository) ListMessages(
+	conversationID int64, limit int,
+) ([]models.ChatMessage, error) {
+	query := `
+		SELECT id, conversation_id, role, content, search_query, citations, created_at
+		FROM (
+			SELECT * FROM messages WHERE conversation_id = $1
+			ORDER BY id DESC
+			LIMIT $2
+		) m
+		ORDER BY id
+	`
+	var limitArg any
+	if limit > 0 {
+		limitArg = limit
+	}
+	rows, err := r.db.Query(r.ctx, query, conversationID, limitArg)
+	if err != nil {
+		return nil, fmt.Errorf("list messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.ChatMessage{}
+	for rows.Next() {
+		var m models.ChatMessage
+		if err := rows.Scan(
+			&m.ID, &m.ConversationID, &m.Role, &m.Content, &m.SearchQuery,
+			&m.Citations, &m.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
@@ -3,34 +3,52 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pgvector/pgvector-go"
 
+	"github.com/AndB0ndar/doc-archive/internal/config"
 	"github.com/AndB0ndar/doc-archive/internal/models"
 )
 
 type ChunkRepository struct {
-	ctx context.Context
-	db  *pgxpool.Pool
+	ctx    context.Context
+	db     *pgxpool.Pool
+	vector *vectorIndex
+	scan   scanSupport
 }
 
-func NewChunkRepository(db *pgxpool.Pool) *ChunkRepository {
+func NewChunkRepository(
+	db *pgxpool.Pool, index config.VectorIndexConfig, dimension int,
+) *ChunkRepository {
 	return &ChunkRepository{
-		ctx: context.Background(),
-		db:  db,
+		ctx:    context.Background(),
+		db:     db,
+		vector: newVectorIndex(index, dimension),
 	}
 }
 
 func (r *ChunkRepository) Create(chunk *models.Chunk) (int64, error) {
 	query := `
-		INSERT INTO chunks (document_id, chunk_index, content, embedding)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO chunks (
+			document_id, chunk_index, content, embedding, page_start, page_end,
+			embedding_model, embedding_dim
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, nullif($7, ''), nullif($8, 0))
 		RETURNING id, created_at
 	`
-	vec := pgvector.NewVector(chunk.Embedding)
+	// A chunk whose embedding failed is stored without one, for the backfill
+	var vec *pgvector.Vector
+	if len(chunk.Embedding) > 0 {
+		v := pgvector.NewVector(chunk.Embedding)
+		vec = &v
+	}
 	err := r.db.QueryRow(r.ctx, query,
 		chunk.DocumentID, chunk.ChunkIndex, chunk.Content, vec,
+		chunk.PageStart, chunk.PageEnd,
+		chunk.EmbeddingModel, len(chunk.Embedding),
 	).Scan(&chunk.ID, &chunk.CreatedAt)
 	if err != nil {
 		return 0, fmt.Errorf("insert chunk: %w", err)
@@ -39,31 +57,56 @@ func (r *ChunkRepository) Create(chunk *models.Chunk) (int64, error) {
 	return chunk.ID, nil
 }
 
+// SearchOptions narrows and pages a chunk search.
+type SearchOptions struct {
+	Filters  models.SearchFilters
+	After    *models.SearchCursor // last row of the previous page
+	Limit    int
+	MinScore float64 // drop rows scoring below it, 0 keeps all
+	Scan     VectorScan
+	Trace    *QueryTrace
+}
+
 func (r *ChunkRepository) FullTextSearchChunks(
-	query string, userID, limit int,
+	query string, userID int, opts SearchOptions,
 ) ([]models.ChunkSearchResponse, error) {
+	limit := opts.Limit
 	if limit <= 0 {
 		limit = 20
 	}
-	sqlQuery := `
+	// <% lets the GIN trigram index pick the chunks whose word similarity
+	// reaches pg_trgm.word_similarity_threshold
+	where, args := appendSearchFilters(
+		[]string{"d.user_id = $2", "$1 <% c.content"}, []any{query, userID}, opts.Filters,
+	)
+	if opts.MinScore > 0 {
+		args = append(args, opts.MinScore)
+		where = append(where, fmt.Sprintf("word_similarity($1, c.content) >= $%d", len(args)))
+	}
+	where, args = appendKeyset(where, args, "word_similarity($1, c.content)", opts.After)
+	args = append(args, limit)
+	sqlQuery := fmt.Sprintf(`
         SELECT 
             c.id,
 			c.document_id,
 			c.chunk_index,
 			c.content,
 			c.created_at,
-            similarity(c.content, $1) AS similarity,
+            word_similarity($1, c.content) AS similarity,
+			c.page_start,
+			c.page_end,
             d.title,
 			d.authors,
 			d.year,
 			d.category
         FROM chunks c
 		JOIN documents d ON c.document_id = d.id
-		WHERE d.user_id = $2
-		ORDER BY similarity(c.content, $1) DESC
-        LIMIT $3
-    ` // WHERE c.content % $1
-	rows, err := r.db.Query(r.ctx, sqlQuery, query, userID, limit)
+		WHERE %s
+		ORDER BY word_similarity($1, c.content) DESC, c.id
+        LIMIT $%d
+    `, strings.Join(where, " AND "), len(args))
+	start := time.Now()
+	rows, err := r.db.Query(r.ctx, sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("full text search chunks: %w", err)
 	}
@@ -76,31 +119,158 @@ func (r *ChunkRepository) FullTextSearchChunks(
 			&r.ChunkID, &r.DocumentID, &r.ChunkIndex, &r.Content,
 			&r.CreatedAt,
 			&r.Similarity,
+			&r.PageStart, &r.PageEnd,
 			&r.Title, &r.Authors, &r.Year, &r.Category,
 		); err != nil {
 			return nil, fmt.Errorf("scan chunk result: %w", err)
 		}
 		results = append(results, r)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("full text search chunks: %w", err)
+	}
+	rows.Close()
+	if err := opts.Trace.finish(r.ctx, r.db, sqlQuery, args, start); err != nil {
+		return nil, err
+	}
 	return results, nil
 }
 
+// CountTextMatches counts chunks whose words are similar to the query
+// (pg_trgm word similarity above its threshold and minScore), up to limit.
+func (r *ChunkRepository) CountTextMatches(
+	query string, userID int, filters models.SearchFilters, minScore float64, limit int,
+) (int, error) {
+	where, args := appendSearchFilters(
+		[]string{"d.user_id = $2", "$1 <% c.content"}, []any{query, userID}, filters,
+	)
+	if minScore > 0 {
+		args = append(args, minScore)
+		where = append(where, fmt.Sprintf("word_similarity($1, c.content) >= $%d", len(args)))
+	}
+	return r.count(where, args, limit)
+}
+
+// CountEmbedded counts chunks with embeddings matching the filters, up to
+// limit. With minScore > 0 only chunks at least that similar to embedding
+// count, which scans the filtered chunks instead of the index.
+func (r *ChunkRepository) CountEmbedded(
+	embedding []float32,
+	userID int,
+	filters models.SearchFilters,
+	minScore float64,
+	limit int,
+) (int, error) {
+	where, args := appendSearchFilters(
+		[]string{"d.user_id = $1", "c.embedding IS NOT NULL"}, []any{userID}, filters,
+	)
+	if minScore > 0 {
+		args = append(args, pgvector.NewVector(embedding), 1-minScore)
+		where = append(where, fmt.Sprintf(
+			"%s <= $%d", r.vector.distance(fmt.Sprintf("$%d", len(args)-1)), len(args),
+		))
+	}
+	return r.count(where, args, limit)
+}
+
+func (r *ChunkRepository) count(where []string, args []any, limit int) (int, error) {
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+		SELECT count(*) FROM (
+			SELECT 1
+			FROM chunks c
+			JOIN documents d ON c.document_id = d.id
+			WHERE %s
+			LIMIT $%d
+		) hits
+	`, strings.Join(where, " AND "), len(args))
+
+	var total int
+	if err := r.db.QueryRow(r.ctx, query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count chunks: %w", err)
+	}
+	return total, nil
+}
+
+// SemanticSearchChunks runs an approximate nearest neighbour search.
+// Filters are applied while the HNSW index is scanned (iterative scan), so
+// the query still returns limit rows when most neighbours are filtered out.
 func (r *ChunkRepository) SemanticSearchChunks(
-	embedding []float32, userID, limit int,
+	embedding []float32, userID int, opts SearchOptions,
+) ([]models.ChunkSearchResponse, error) {
+	return r.semanticSearch(embedding, userID, opts, false)
+}
+
+// SemanticSearchCandidates is SemanticSearchChunks that also loads
+// the chunk embeddings, for re-ranking in Go.
+func (r *ChunkRepository) SemanticSearchCandidates(
+	embedding []float32, userID int, opts SearchOptions,
 ) ([]models.ChunkSearchResponse, error) {
+	return r.semanticSearch(embedding, userID, opts, true)
+}
+
+func (r *ChunkRepository) semanticSearch(
+	embedding []float32,
+	userID int,
+	opts SearchOptions,
+	withEmbeddings bool,
+) ([]models.ChunkSearchResponse, error) {
+	limit := opts.Limit
 	vec := pgvector.NewVector(embedding)
-	query := `
-		SELECT 
-			c.id, c.document_id, c.chunk_index, c.content, c.created_at,
-			1 - (c.embedding <=> $1) AS similarity,
-			d.title, d.authors, d.year, d.category
-		FROM chunks c
-		JOIN documents d ON c.document_id = d.id
-		WHERE c.embedding IS NOT NULL AND d.user_id = $2
-		ORDER BY c.embedding <=> $1
-		LIMIT $3
-	`
-	rows, err := r.db.Query(r.ctx, query, vec, userID, limit)
+	where, args := appendSearchFilters(
+		[]string{"c.embedding IS NOT NULL", "d.user_id = $2"},
+		[]any{vec, userID}, opts.Filters,
+	)
+	distance := r.vector.distance("$1")
+	where, args = appendKeyset(where, args, "1 - ("+distance+")", opts.After)
+	args = append(args, limit)
+	limitArg := len(args)
+	// The threshold is applied to the nearest rows only, the index
+	// scan itself stays bounded by the limit
+	threshold := ""
+	if opts.MinScore > 0 {
+		args = append(args, 1-opts.MinScore)
+		threshold = fmt.Sprintf("WHERE distance <= $%d", len(args))
+	}
+	embeddingColumn := ""
+	if withEmbeddings {
+		embeddingColumn = ", embedding"
+	}
+	query := fmt.Sprintf(`
+		WITH candidates AS MATERIALIZED (
+			SELECT 
+				c.id, c.document_id, c.chunk_index, c.content, c.created_at,
+				c.page_start, c.page_end,
+				c.embedding, %[1]s AS distance,
+				d.title, d.authors, d.year, d.category
+			FROM chunks c
+			JOIN documents d ON c.document_id = d.id
+			WHERE %[2]s
+			ORDER BY %[1]s, c.id
+			LIMIT $%[3]d
+		)
+		SELECT
+			id, document_id, chunk_index, content, created_at,
+			1 - distance AS similarity,
+			page_start, page_end,
+			title, authors, year, category%[4]s
+		FROM candidates
+		%[5]s
+		ORDER BY distance, id
+	`, distance, strings.Join(where, " AND "), limitArg, embeddingColumn, threshold)
+
+	tx, err := r.db.Begin(r.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(r.ctx)
+	iterative := r.scan.iterativeScan(r.ctx, r.db)
+	if err := r.vector.setScanOptions(r.ctx, tx, limit, opts.Scan, iterative); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	rows, err := tx.Query(r.ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("semantic search chunks: %w", err)
 	}
@@ -109,14 +279,29 @@ func (r *ChunkRepository) SemanticSearchChunks(
 	var results []models.ChunkSearchResponse
 	for rows.Next() {
 		var r models.ChunkSearchResponse
-		if err := rows.Scan(
+		var emb pgvector.Vector
+		dest := []any{
 			&r.ChunkID, &r.DocumentID, &r.ChunkIndex, &r.Content, &r.CreatedAt,
 			&r.Similarity,
+			&r.PageStart, &r.PageEnd,
 			&r.Title, &r.Authors, &r.Year, &r.Category,
-		); err != nil {
+		}
+		if withEmbeddings {
+			dest = append(dest, &emb)
+		}
+		if err := rows.Scan(dest...); err != nil {
 			return nil, fmt.Errorf("scan chunk result: %w", err)
 		}
+		r.Embedding = emb.Slice()
 		results = append(results, r)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("semantic search chunks: %w", err)
+	}
+	rows.Close()
+	// Explained in the same transaction, under the same scan options
+	if err := opts.Trace.finish(r.ctx, tx, query, args, start); err != nil {
+		return nil, err
+	}
 	return results, nil
 }
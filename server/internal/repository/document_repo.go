@@ -2,16 +2,22 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgvector/pgvector-go"
 
 	"github.com/AndB0ndar/doc-archive/internal/models"
 )
 
+// ErrNoEmbedding is returned for documents whose chunks are not embedded yet.
+var ErrNoEmbedding = errors.New("document has no embedding")
+
 type DocumentRepository struct {
-	ctx context.Context
-	db  *pgxpool.Pool
+	ctx  context.Context
+	db   *pgxpool.Pool
+	scan scanSupport
 }
 
 func NewDocumentRepository(db *pgxpool.Pool) *DocumentRepository {
@@ -23,12 +29,12 @@ func NewDocumentRepository(db *pgxpool.Pool) *DocumentRepository {
 
 func (r *DocumentRepository) Create(doc *models.Document) (int, error) {
 	query := `
-        INSERT INTO documents (title, authors, year, category, file_path, file_size, user_id)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        INSERT INTO documents (title, authors, year, category, file_path, file_size, format, user_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
         RETURNING id, created_at
     `
 	err := r.db.QueryRow(r.ctx, query,
-		doc.Title, doc.Authors, doc.Year, doc.Category, doc.FilePath, doc.FileSize, doc.UserID,
+		doc.Title, doc.Authors, doc.Year, doc.Category, doc.FilePath, doc.FileSize, doc.Format, doc.UserID,
 	).Scan(&doc.ID, &doc.CreatedAt)
 	if err != nil {
 		return 0, fmt.Errorf("insert document: %w", err)
@@ -46,13 +52,14 @@ func (r *DocumentRepository) GetByID(id, userID int) (*models.Document, error) {
 			category,
 			file_path,
 			file_size,
+			format,
 			created_at
 		FROM documents WHERE id = $1 AND user_id = $2
 	`
 	var doc models.Document
 	err := r.db.QueryRow(r.ctx, query, id, userID).Scan(
 		&doc.ID, &doc.Title, &doc.Authors, &doc.Year, &doc.Category,
-		&doc.FilePath, &doc.FileSize, &doc.CreatedAt,
+		&doc.FilePath, &doc.FileSize, &doc.Format, &doc.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -75,6 +82,7 @@ func (r *DocumentRepository) GetAll(
 			category,
 			file_path,
 			file_size,
+			format,
 			created_at
 		FROM documents WHERE user_id = $1 ORDER BY created_at DESC
         LIMIT $2 OFFSET $3
@@ -90,7 +98,7 @@ func (r *DocumentRepository) GetAll(
 		var d models.Document
 		if err := rows.Scan(
 			&d.ID, &d.Title, &d.Authors, &d.Year, &d.Category,
-			&d.FilePath, &d.FileSize, &d.CreatedAt,
+			&d.FilePath, &d.FileSize, &d.Format, &d.CreatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("scan document: %w", err)
 		}
@@ -110,3 +118,126 @@ func (r *DocumentRepository) Delete(id, userID int) error {
 	}
 	return nil
 }
+
+// UpdateEmbedding sets the document embedding to the mean of its chunk
+// embeddings (NULL while no chunk is embedded).
+func (r *DocumentRepository) UpdateEmbedding(id int) error {
+	query := `
+		UPDATE documents
+		SET embedding = (
+			SELECT avg(embedding)
+			FROM chunks
+			WHERE document_id = $1 AND embedding IS NOT NULL
+		)
+		WHERE id = $1
+	`
+	if _, err := r.db.Exec(r.ctx, query, id); err != nil {
+		return fmt.Errorf("update document embedding: %w", err)
+	}
+	return nil
+}
+
+// FindSimilar returns the user's documents nearest to the document id by
+// cosine similarity of document embeddings, the document itself excluded.
+func (r *DocumentRepository) FindSimilar(
+	id, userID, limit int,
+) ([]models.SimilarDocument, error) {
+	var embedding *pgvector.Vector
+	err := r.db.QueryRow(r.ctx,
+		`SELECT embedding FROM documents WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	).Scan(&embedding)
+	if err != nil {
+		return nil, fmt.Errorf("get document embedding: %w", err)
+	}
+	if embedding == nil {
+		return nil, ErrNoEmbedding
+	}
+
+	query := `
+		SELECT
+			id,
+			title,
+			authors,
+			year,
+			category,
+			file_path,
+			file_size,
+			format,
+			created_at,
+			1 - (embedding <=> $1) AS similarity
+		FROM documents
+		WHERE user_id = $2 AND id <> $3 AND embedding IS NOT NULL
+		ORDER BY embedding <=> $1
+		LIMIT $4
+	`
+	rows, err := r.db.Query(r.ctx, query, *embedding, userID, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("find similar documents: %w", err)
+	}
+	defer rows.Close()
+
+	docs := []models.SimilarDocument{}
+	for rows.Next() {
+		var d models.SimilarDocument
+		if err := rows.Scan(
+			&d.ID, &d.Title, &d.Authors, &d.Year, &d.Category,
+			&d.FilePath, &d.FileSize, &d.Format, &d.CreatedAt, &d.Similarity,
+		); err != nil {
+			return nil, fmt.Errorf("scan similar document: %w", err)
+		}
+		d.UserID = userID
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// DocumentVectorSource holds what the search vector of a document is built
+// from. Abstract is nil until extracted, Centroid while no chunk is embedded.
+type DocumentVectorSource struct {
+	Title    string
+	Authors  *string
+	Abstract *string
+	FilePath string
+	Centroid []float32
+}
+
+// VectorSource loads the parts of the document's search vector.
+func (r *DocumentRepository) VectorSource(id int) (*DocumentVectorSource, error) {
+	var src DocumentVectorSource
+	var centroid *pgvector.Vector
+	err := r.db.QueryRow(r.ctx, `
+		SELECT title, authors, abstract, file_path, embedding
+		FROM documents
+		WHERE id = $1
+	`, id).Scan(&src.Title, &src.Authors, &src.Abstract, &src.FilePath, &centroid)
+	if err != nil {
+		return nil, fmt.Errorf("get document vector source: %w", err)
+	}
+	if centroid != nil {
+		src.Centroid = centroid.Slice()
+	}
+	return &src, nil
+}
+
+// SetAbstract stores the abstract (or first page) of the document, empty
+// when the text could not be extracted.
+func (r *DocumentRepository) SetAbstract(id int, abstract string) error {
+	if _, err := r.db.Exec(r.ctx,
+		`UPDATE documents SET abstract = $2 WHERE id = $1`, id, abstract,
+	); err != nil {
+		return fmt.Errorf("set document abstract: %w", err)
+	}
+	return nil
+}
+
+// SetSearchEmbedding stores the vector matched by document search.
+func (r *DocumentRepository) SetSearchEmbedding(id int, embedding []float32) error {
+	if _, err := r.db.Exec(r.ctx,
+		`UPDATE documents SET search_embedding = $2 WHERE id = $1`,
+		id, pgvector.NewVector(embedding),
+	); err != nil {
+		return fmt.Errorf("set document search embedding: %w", err)
+	}
+	return nil
+}
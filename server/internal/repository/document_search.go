@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+
+	"github.com/AndB0ndar/doc-archive/internal/config"
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+// documentIndex is the HNSW index on documents.search_embedding; only its
+// scan options are used.
+var documentIndex = newVectorIndex(config.VectorIndexConfig{Type: "hnsw"}, 0)
+
+// SearchDocuments ranks the user's documents by cosine similarity of their
+// search vector (title, abstract and chunk centroid) to embedding.
+func (r *DocumentRepository) SearchDocuments(
+	embedding []float32, userID int, opts SearchOptions,
+) ([]models.SimilarDocument, error) {
+	where, args := appendDocumentFilters(
+		[]string{"d.search_embedding IS NOT NULL", "d.user_id = $2"},
+		[]any{pgvector.NewVector(embedding), userID}, opts.Filters,
+	)
+	args = append(args, opts.Limit)
+	limitArg := len(args)
+	threshold := ""
+	if opts.MinScore > 0 {
+		args = append(args, 1-opts.MinScore)
+		threshold = fmt.Sprintf("WHERE distance <= $%d", len(args))
+	}
+	query := fmt.Sprintf(`
+		WITH candidates AS MATERIALIZED (
+			SELECT
+				d.id, d.title, d.authors, d.year, d.category,
+				d.file_path, d.file_size, d.format, d.created_at,
+				d.search_embedding <=> $1 AS distance
+			FROM documents d
+			WHERE %s
+			ORDER BY d.search_embedding <=> $1
+			LIMIT $%d
+		)
+		SELECT
+			id, title, authors, year, category,
+			file_path, file_size, format, created_at,
+			1 - distance AS similarity
+		FROM candidates
+		%s
+		ORDER BY distance, id
+	`, strings.Join(where, " AND "), limitArg, threshold)
+
+	tx, err := r.db.Begin(r.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(r.ctx)
+	iterative := r.scan.iterativeScan(r.ctx, r.db)
+	if err := documentIndex.setScanOptions(r.ctx, tx, opts.Limit, opts.Scan, iterative); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	rows, err := tx.Query(r.ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search documents: %w", err)
+	}
+	defer rows.Close()
+
+	docs := []models.SimilarDocument{}
+	for rows.Next() {
+		var d models.SimilarDocument
+		if err := rows.Scan(
+			&d.ID, &d.Title, &d.Authors, &d.Year, &d.Category,
+			&d.FilePath, &d.FileSize, &d.Format, &d.CreatedAt, &d.Similarity,
+		); err != nil {
+			return nil, fmt.Errorf("scan document result: %w", err)
+		}
+		d.UserID = userID
+		docs = append(docs, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search documents: %w", err)
+	}
+	rows.Close()
+	if err := opts.Trace.finish(r.ctx, tx, query, args, start); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// CountDocuments counts the documents with a search vector matching the
+// filters, up to limit. With minScore > 0 only documents at least that
+// similar to embedding count.
+func (r *DocumentRepository) CountDocuments(
+	embedding []float32,
+	userID int,
+	filters models.SearchFilters,
+	minScore float64,
+	limit int,
+) (int, error) {
+	where, args := appendDocumentFilters(
+		[]string{"d.user_id = $1", "d.search_embedding IS NOT NULL"}, []any{userID}, filters,
+	)
+	if minScore > 0 {
+		args = append(args, pgvector.NewVector(embedding), 1-minScore)
+		where = append(where, fmt.Sprintf(
+			"d.search_embedding <=> $%d <= $%d", len(args)-1, len(args),
+		))
+	}
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+		SELECT count(*) FROM (
+			SELECT 1
+			FROM documents d
+			WHERE %s
+			LIMIT $%d
+		) hits
+	`, strings.Join(where, " AND "), len(args))
+
+	var total int
+	if err := r.db.QueryRow(r.ctx, query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count documents: %w", err)
+	}
+	return total, nil
+}
+
+// DocumentFacets counts, per value of field, the documents among the
+// candidates nearest to embedding by search vector that pass the filters.
+func (r *DocumentRepository) DocumentFacets(
+	embedding []float32,
+	userID int,
+	filters models.SearchFilters,
+	field string,
+	size, candidates int,
+) ([]models.FacetCount, error) {
+	where, args := appendDocumentFilters(
+		[]string{"d.search_embedding IS NOT NULL", "d.user_id = $2"},
+		[]any{pgvector.NewVector(embedding), userID}, filters,
+	)
+	args = append(args, candidates)
+	matched := fmt.Sprintf(`
+		SELECT d.id, d.category, d.year, d.authors, d.format
+		FROM documents d
+		WHERE %s
+		ORDER BY d.search_embedding <=> $1
+		LIMIT $%d
+	`, strings.Join(where, " AND "), len(args))
+
+	tx, err := r.db.Begin(r.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(r.ctx)
+	iterative := r.scan.iterativeScan(r.ctx, r.db)
+	err = documentIndex.setScanOptions(r.ctx, tx, candidates, VectorScan{}, iterative)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(r.ctx, facetSQL(matched, field, len(args)+1), append(args, size)...)
+	if err != nil {
+		return nil, fmt.Errorf("document facets: %w", err)
+	}
+	return scanFacets(rows)
+}
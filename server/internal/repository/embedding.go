@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pgvector/pgvector-go"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+var ErrReembedPending = fmt.Errorf("chunks are still being re-embedded")
+
+var documentIndexesSQL = []string{`
+	CREATE INDEX idx_documents_embedding ON documents
+		USING hnsw (embedding vector_cosine_ops)
+`, `
+	CREATE INDEX idx_documents_search_embedding ON documents
+		USING hnsw (search_embedding vector_cosine_ops)
+`}
+
+// EmbeddingColumnDimension returns the declared length of chunks.embedding.
+func (r *ChunkRepository) EmbeddingColumnDimension() (int, error) {
+	var dim int
+	// atttypmod of a vector column is its dimension
+	err := r.db.QueryRow(r.ctx, `
+		SELECT atttypmod FROM pg_attribute
+		WHERE attrelid = 'chunks'::regclass AND attname = 'embedding'
+	`).Scan(&dim)
+	if err != nil {
+		return 0, fmt.Errorf("get embedding dimension: %w", err)
+	}
+	return dim, nil
+}
+
+// EmbeddingModels counts the embedded chunks per model and dimension.
+// Chunks stored before models were tracked have an empty model.
+func (r *ChunkRepository) EmbeddingModels() ([]models.EmbeddingModelStats, error) {
+	rows, err := r.db.Query(r.ctx, `
+		SELECT coalesce(embedding_model, ''), vector_dims(embedding), count(*)
+		FROM chunks
+		WHERE embedding IS NOT NULL
+		GROUP BY 1, 2
+		ORDER BY 3 DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("count embedding models: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.EmbeddingModelStats
+	for rows.Next() {
+		var s models.EmbeddingModelStats
+		if err := rows.Scan(&s.Model, &s.Dimension, &s.Chunks); err != nil {
+			return nil, fmt.Errorf("scan embedding model: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// TagEmbeddingModel records model for the embedded chunks that have none.
+func (r *ChunkRepository) TagEmbeddingModel(model string) (int64, error) {
+	tag, err := r.db.Exec(r.ctx, `
+		UPDATE chunks
+		SET embedding_model = $1, embedding_dim = vector_dims(embedding)
+		WHERE embedding IS NOT NULL AND embedding_model IS NULL
+	`, model)
+	if err != nil {
+		return 0, fmt.Errorf("tag embedding model: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ResizeEmbeddings changes the vector columns of chunks and documents to
+// dimension and rebuilds their indexes. Stored vectors cannot be converted,
+// so it is meant for an archive without embeddings.
+func (r *ChunkRepository) ResizeEmbeddings(dimension int) error {
+	tx, err := r.db.Begin(r.ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(r.ctx)
+
+	if err := r.rebuildVectorColumns(tx, dimension, "NULL"); err != nil {
+		return err
+	}
+	if err := tx.Commit(r.ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	r.vector.dimension.Store(int32(dimension))
+	return nil
+}
+
+// PendingReembed returns up to limit embedded chunks that have no vector
+// of model yet, with id above afterID in id order.
+func (r *ChunkRepository) PendingReembed(
+	model string, afterID int64, limit int,
+) ([]models.Chunk, error) {
+	rows, err := r.db.Query(r.ctx, `
+		SELECT id, document_id, chunk_index, content
+		FROM chunks
+		WHERE embedding IS NOT NULL AND embedding_next_model IS DISTINCT FROM $1
+			AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`, model, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get chunks to re-embed: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []models.Chunk
+	for rows.Next() {
+		var c models.Chunk
+		if err := rows.Scan(&c.ID, &c.DocumentID, &c.ChunkIndex, &c.Content); err != nil {
+			return nil, fmt.Errorf("scan chunk: %w", err)
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// SetNextEmbedding stores the vector of the model being migrated to.
+func (r *ChunkRepository) SetNextEmbedding(id int64, embedding []float32, model string) error {
+	_, err := r.db.Exec(r.ctx, `
+		UPDATE chunks SET embedding_next = $2, embedding_next_model = $3 WHERE id = $1
+	`, id, pgvector.NewVector(embedding), model)
+	if err != nil {
+		return fmt.Errorf("set next embedding: %w", err)
+	}
+	return nil
+}
+
+// ReembedProgress counts the embedded chunks and those already having a
+// vector of model.
+func (r *ChunkRepository) ReembedProgress(model string) (done, total int, err error) {
+	err = r.db.QueryRow(r.ctx, `
+		SELECT count(*) FILTER (WHERE embedding_next_model = $1), count(*)
+		FROM chunks
+		WHERE embedding IS NOT NULL
+	`, model).Scan(&done, &total)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get re-embed progress: %w", err)
+	}
+	return done, total, nil
+}
+
+// CutoverEmbeddings replaces the searched vectors with those of model in
+// one transaction, then recomputes the document vectors. It fails with
+// ErrReembedPending while a chunk still lacks a vector of model.
+func (r *ChunkRepository) CutoverEmbeddings(model string) (int, error) {
+	tx, err := r.db.Begin(r.ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(r.ctx)
+
+	// Blocks new chunks until the swap is committed
+	if _, err := tx.Exec(r.ctx, "LOCK TABLE chunks IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return 0, fmt.Errorf("lock chunks: %w", err)
+	}
+	var pending, dimensions, dimension int
+	err = tx.QueryRow(r.ctx, `
+		SELECT
+			count(*) FILTER (WHERE embedding_next_model IS DISTINCT FROM $1),
+			count(DISTINCT vector_dims(embedding_next)),
+			coalesce(max(vector_dims(embedding_next)), 0)
+		FROM chunks
+		WHERE embedding IS NOT NULL
+	`, model).Scan(&pending, &dimensions, &dimension)
+	if err != nil {
+		return 0, fmt.Errorf("check re-embed progress: %w", err)
+	}
+	if pending > 0 {
+		return 0, fmt.Errorf("%w: %d chunks left", ErrReembedPending, pending)
+	}
+	if dimensions != 1 {
+		return 0, fmt.Errorf("cutover: %d different vector dimensions", dimensions)
+	}
+
+	using := fmt.Sprintf("embedding_next::vector(%d)", dimension)
+	if err := r.rebuildVectorColumns(tx, dimension, using); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(r.ctx, `
+		UPDATE chunks
+		SET embedding_model = embedding_next_model, embedding_dim = $1,
+			embedding_next = NULL, embedding_next_model = NULL
+		WHERE embedding IS NOT NULL
+	`, dimension)
+	if err != nil {
+		return 0, fmt.Errorf("switch embedding model: %w", err)
+	}
+	_, err = tx.Exec(r.ctx, `
+		UPDATE documents d
+		SET embedding = (
+			SELECT avg(c.embedding) FROM chunks c
+			WHERE c.document_id = d.id AND c.embedding IS NOT NULL
+		)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("update document embeddings: %w", err)
+	}
+	if err := tx.Commit(r.ctx); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	r.vector.dimension.Store(int32(dimension))
+	return dimension, nil
+}
+
+// rebuildVectorColumns retypes the chunk and document vectors to
+// dimension, converting the chunk vectors with using, and recreates their
+// indexes. Document vectors are cleared and must be recomputed, the search
+// vectors by the backfill.
+func (r *ChunkRepository) rebuildVectorColumns(tx pgx.Tx, dimension int, using string) error {
+	statements := []string{
+		"DROP INDEX IF EXISTS idx_chunks_embedding",
+		"DROP INDEX IF EXISTS idx_documents_embedding",
+		"DROP INDEX IF EXISTS idx_documents_search_embedding",
+		fmt.Sprintf(
+			"ALTER TABLE chunks ALTER COLUMN embedding TYPE vector(%d) USING %s",
+			dimension, using,
+		),
+		fmt.Sprintf(
+			"ALTER TABLE documents ALTER COLUMN embedding TYPE vector(%d) USING NULL",
+			dimension,
+		),
+		fmt.Sprintf(
+			"ALTER TABLE documents ALTER COLUMN search_embedding TYPE vector(%d) USING NULL",
+			dimension,
+		),
+	}
+	for _, sql := range statements {
+		if _, err := tx.Exec(r.ctx, sql); err != nil {
+			return fmt.Errorf("resize embeddings: %w", err)
+		}
+	}
+
+	// The index is built on the new dimension
+	index := newVectorIndex(r.vector.VectorIndexConfig, dimension)
+	for _, sql := range append([]string{index.createSQL()}, documentIndexesSQL...) {
+		if _, err := tx.Exec(r.ctx, sql); err != nil {
+			return fmt.Errorf("create vector index: %w", err)
+		}
+	}
+	return nil
+}
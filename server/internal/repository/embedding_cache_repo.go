@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgvector/pgvector-go"
+)
+
+type EmbeddingCacheRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewEmbeddingCacheRepository(db *pgxpool.Pool) *EmbeddingCacheRepository {
+	return &EmbeddingCacheRepository{db: db}
+}
+
+// Get returns the cached embedding of the text hash, nil when there is none.
+func (r *EmbeddingCacheRepository) Get(
+	ctx context.Context, model string, textHash []byte,
+) ([]float32, error) {
+	var vec pgvector.Vector
+	err := r.db.QueryRow(ctx,
+		`SELECT embedding FROM embedding_cache WHERE model = $1 AND text_hash = $2`,
+		model, textHash,
+	).Scan(&vec)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get cached embedding: %w", err)
+	}
+	return vec.Slice(), nil
+}
+
+func (r *EmbeddingCacheRepository) Put(
+	ctx context.Context, model string, textHash []byte, embedding []float32,
+) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO embedding_cache (model, text_hash, embedding)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (model, text_hash) DO NOTHING
+	`, model, textHash, pgvector.NewVector(embedding))
+	if err != nil {
+		return fmt.Errorf("cache embedding: %w", err)
+	}
+	return nil
+}
+
+// DeleteExcept drops the cached embeddings of every model but keep.
+func (r *EmbeddingCacheRepository) DeleteExcept(
+	ctx context.Context, keep ...string,
+) (int64, error) {
+	tag, err := r.db.Exec(ctx,
+		`DELETE FROM embedding_cache WHERE NOT (model = ANY($1))`, keep,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete cached embeddings: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
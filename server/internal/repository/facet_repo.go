@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pgvector/pgvector-go"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+// Facet value expressions over the matched documents (m)
+var facetColumns = map[string]string{
+	"category": "m.category",
+	"year":     "m.year::text",
+	"format":   "m.format",
+	// authors is free text, count every name of a comma or semicolon list
+	"author": `btrim(unnest(regexp_split_to_array(m.authors, '\s*[,;]\s*')))`,
+}
+
+func IsFacetField(field string) bool {
+	_, ok := facetColumns[field]
+	return ok
+}
+
+// TextFacets counts, per value of field, the documents that have a chunk
+// similar to the query (pg_trgm word similarity) and pass the filters.
+func (r *ChunkRepository) TextFacets(
+	query string, userID int, filters models.SearchFilters, field string, size int,
+) ([]models.FacetCount, error) {
+	where, args := appendSearchFilters(
+		[]string{"d.user_id = $2", "$1 <% c.content"}, []any{query, userID}, filters,
+	)
+	matched := fmt.Sprintf(`
+		SELECT DISTINCT d.id, d.category, d.year, d.authors, d.format
+		FROM chunks c
+		JOIN documents d ON c.document_id = d.id
+		WHERE %s
+	`, strings.Join(where, " AND "))
+
+	rows, err := r.db.Query(r.ctx, facetSQL(matched, field, len(args)+1), append(args, size)...)
+	if err != nil {
+		return nil, fmt.Errorf("text facets: %w", err)
+	}
+	return scanFacets(rows)
+}
+
+// SemanticFacets counts, per value of field, the documents owning one of
+// the candidates nearest chunks that pass the filters.
+func (r *ChunkRepository) SemanticFacets(
+	embedding []float32,
+	userID int,
+	filters models.SearchFilters,
+	field string,
+	size, candidates int,
+) ([]models.FacetCount, error) {
+	where, args := appendSearchFilters(
+		[]string{"c.embedding IS NOT NULL", "d.user_id = $2"},
+		[]any{pgvector.NewVector(embedding), userID}, filters,
+	)
+	args = append(args, candidates)
+	matched := fmt.Sprintf(`
+		SELECT d.id, d.category, d.year, d.authors, d.format
+		FROM documents d
+		WHERE d.id IN (
+			SELECT document_id FROM (
+				SELECT c.document_id
+				FROM chunks c
+				JOIN documents d ON c.document_id = d.id
+				WHERE %s
+				ORDER BY %s
+				LIMIT $%d
+			) nearest
+		)
+	`, strings.Join(where, " AND "), r.vector.distance("$1"), len(args))
+
+	tx, err := r.db.Begin(r.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(r.ctx)
+	iterative := r.scan.iterativeScan(r.ctx, r.db)
+	if err := r.vector.setScanOptions(r.ctx, tx, candidates, VectorScan{}, iterative); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(r.ctx, facetSQL(matched, field, len(args)+1), append(args, size)...)
+	if err != nil {
+		return nil, fmt.Errorf("semantic facets: %w", err)
+	}
+	return scanFacets(rows)
+}
+
+func facetSQL(matched, field string, sizeArg int) string {
+	return fmt.Sprintf(`
+		WITH m AS (%s),
+		facet AS (SELECT m.id, %s AS value FROM m)
+		SELECT value, count(DISTINCT id)
+		FROM facet
+		WHERE value IS NOT NULL AND value <> ''
+		GROUP BY value
+		ORDER BY count(DISTINCT id) DESC, value
+		LIMIT $%d
+	`, matched, facetColumns[field], sizeArg)
+}
+
+func scanFacets(rows pgx.Rows) ([]models.FacetCount, error) {
+	defer rows.Close()
+	facets := []models.FacetCount{}
+	for rows.Next() {
+		var f models.FacetCount
+		if err := rows.Scan(&f.Value, &f.Count); err != nil {
+			return nil, fmt.Errorf("scan facet: %w", err)
+		}
+		facets = append(facets, f)
+	}
+	return facets, rows.Err()
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// appendSearchFilters adds the conditions of f on the documents (d) and
+// chunks (c) tables to where, numbering placeholders after the existing args.
+func appendSearchFilters(
+	where []string, args []any, f models.SearchFilters,
+) ([]string, []any) {
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if len(f.DocumentIDs) > 0 {
+		add("d.id = ANY($%d)", f.DocumentIDs)
+	}
+	if len(f.Categories) > 0 {
+		add("d.category = ANY($%d)", f.Categories)
+	}
+	if f.YearFrom != nil {
+		add("d.year >= $%d", *f.YearFrom)
+	}
+	if f.YearTo != nil {
+		add("d.year <= $%d", *f.YearTo)
+	}
+	if f.Author != "" {
+		add(`d.authors ILIKE '%%' || $%d || '%%'`, likeEscaper.Replace(f.Author))
+	}
+	if f.UploadedFrom != nil {
+		add("d.created_at >= $%d", *f.UploadedFrom)
+	}
+	if f.UploadedTo != nil {
+		add("d.created_at <= $%d", *f.UploadedTo)
+	}
+	if len(f.Formats) > 0 {
+		add("d.format = ANY($%d)", f.Formats)
+	}
+	for _, phrase := range f.Phrases {
+		add(`c.content ILIKE '%%' || $%d || '%%'`, likeEscaper.Replace(phrase))
+	}
+	for _, term := range f.Exclude {
+		add(`c.content NOT ILIKE '%%' || $%d || '%%'`, likeEscaper.Replace(term))
+	}
+	return where, args
+}
+
+// appendKeyset restricts a query ordered by score DESC, c.id to the rows
+// after the cursor.
+func appendKeyset(
+	where []string, args []any, score string, after *models.SearchCursor,
+) ([]string, []any) {
+	if after == nil {
+		return where, args
+	}
+	args = append(args, after.Score, after.ChunkID)
+	s, id := len(args)-1, len(args)
+	where = append(where, fmt.Sprintf(
+		"(%[1]s < $%[2]d OR (%[1]s = $%[2]d AND c.id > $%[3]d))", score, s, id,
+	))
+	return where, args
+}
+
+// appendDocumentFilters is appendSearchFilters for queries over documents
+// (d) alone: a phrase must appear in some chunk of the document and an
+// exclusion in none.
+func appendDocumentFilters(
+	where []string, args []any, f models.SearchFilters,
+) ([]string, []any) {
+	phrases, exclude := f.Phrases, f.Exclude
+	f.Phrases, f.Exclude = nil, nil
+	where, args = appendSearchFilters(where, args, f)
+
+	add := func(format, term string) {
+		args = append(args, likeEscaper.Replace(term))
+		where = append(where, fmt.Sprintf(format, len(args)))
+	}
+	for _, phrase := range phrases {
+		add(`EXISTS (SELECT 1 FROM chunks c WHERE c.document_id = d.id
+			AND c.content ILIKE '%%' || $%d || '%%')`, phrase)
+	}
+	for _, term := range exclude {
+		add(`NOT EXISTS (SELECT 1 FROM chunks c WHERE c.document_id = d.id
+			AND c.content ILIKE '%%' || $%d || '%%')`, term)
+	}
+	return where, args
+}
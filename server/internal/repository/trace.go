@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QueryTrace records the SQL run by a search and how long it took,
+// for explain output. The plan is loaded only when WithPlan is set.
+type QueryTrace struct {
+	WithPlan bool
+	SQL      string
+	Duration time.Duration
+	Plan     json.RawMessage
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// finish stores the timing of a query started at start and, when asked,
+// its EXPLAIN plan. A nil trace does nothing.
+func (t *QueryTrace) finish(
+	ctx context.Context, db queryRower, sql string, args []any, start time.Time,
+) error {
+	if t == nil {
+		return nil
+	}
+	t.Duration = time.Since(start)
+	t.SQL = strings.TrimSpace(sql)
+	if !t.WithPlan {
+		return nil
+	}
+	var plan []byte
+	if err := db.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+sql, args...).Scan(&plan); err != nil {
+		return fmt.Errorf("explain query: %w", err)
+	}
+	t.Plan = plan
+	return nil
+}
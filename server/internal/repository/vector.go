@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/AndB0ndar/doc-archive/internal/config"
+)
+
+var ErrVectorIndexMismatch = fmt.Errorf("vector index does not match the settings")
+
+const (
+	// Candidates per requested row kept by the HNSW scan
+	vectorOverfetchFactor = 4
+	minEfSearch           = 40
+	// Bounds of the per-request hnsw.ef_search and ivfflat.probes
+	MaxEfSearch = 1000
+	MaxProbes   = 1000
+)
+
+// vectorIndex describes how chunk embeddings are indexed, so queries
+// use the distance expression and scan settings the index serves.
+type vectorIndex struct {
+	config.VectorIndexConfig
+	// Length of the stored vectors, changed by a re-embedding cutover
+	dimension atomic.Int32
+}
+
+func newVectorIndex(cfg config.VectorIndexConfig, dimension int) *vectorIndex {
+	o := &vectorIndex{VectorIndexConfig: cfg}
+	o.dimension.Store(int32(dimension))
+	return o
+}
+
+func (o *vectorIndex) halfVec() bool {
+	return o.Storage == "halfvec"
+}
+
+// createSQL builds idx_chunks_embedding like the vector index migration.
+func (o *vectorIndex) createSQL() string {
+	indexed, ops := "embedding", "vector_cosine_ops"
+	if o.halfVec() {
+		indexed = fmt.Sprintf("(embedding::halfvec(%d))", o.dimension.Load())
+		ops = "halfvec_cosine_ops"
+	}
+	params := fmt.Sprintf("m = %d, ef_construction = %d", o.HNSWM, o.HNSWEfConstruction)
+	if o.Type == "ivfflat" {
+		params = fmt.Sprintf("lists = %d", o.IVFFlatLists)
+	}
+	return fmt.Sprintf(
+		"CREATE INDEX idx_chunks_embedding ON chunks USING %s (%s %s) WITH (%s)",
+		o.Type, indexed, ops, params,
+	)
+}
+
+// CheckVectorIndex makes sure idx_chunks_embedding is built the way
+// VECTOR_INDEX, VECTOR_STORAGE and the stored dimension say, since the
+// queries are written for that index and would fall back to sequential
+// scans otherwise. A missing index is built; one that differs is an error.
+func (r *ChunkRepository) CheckVectorIndex() error {
+	var def *string
+	err := r.db.QueryRow(r.ctx,
+		`SELECT pg_get_indexdef(to_regclass('idx_chunks_embedding'))`,
+	).Scan(&def)
+	if err != nil {
+		return fmt.Errorf("get vector index: %w", err)
+	}
+	if def == nil {
+		slog.Info("building vector index", "type", r.vector.Type, "storage", r.vector.Storage,
+			"dimension", r.vector.dimension.Load())
+		if _, err := r.db.Exec(r.ctx, r.vector.createSQL()); err != nil {
+			return fmt.Errorf("create vector index: %w", err)
+		}
+		return nil
+	}
+	if !r.vector.builds(*def) {
+		return fmt.Errorf(
+			"%w: %s does not match VECTOR_INDEX=%s, VECTOR_STORAGE=%s and dimension %d; "+
+				"migrate down to version 5 and up again to rebuild it",
+			ErrVectorIndexMismatch, *def, r.vector.Type, r.vector.Storage, r.vector.dimension.Load(),
+		)
+	}
+	return nil
+}
+
+// builds reports whether the index definition, as pg_get_indexdef prints
+// it, has the access method, operator class and cast of these settings.
+func (o *vectorIndex) builds(def string) bool {
+	column := "(embedding vector_cosine_ops)"
+	if o.halfVec() {
+		column = fmt.Sprintf("::halfvec(%d)) halfvec_cosine_ops)", o.dimension.Load())
+	}
+	return strings.Contains(def, "USING "+o.Type+" ") && strings.Contains(def, column)
+}
+
+// VectorScan overrides the scan settings of one search, zero values
+// keep the defaults.
+type VectorScan struct {
+	EfSearch int
+	Probes   int
+}
+
+// distance is the cosine distance between chunk embeddings (c) and the
+// query vector parameter, in the form the index is built on.
+func (o *vectorIndex) distance(param string) string {
+	if o.halfVec() {
+		return fmt.Sprintf(
+			"c.embedding::halfvec(%[1]d) <=> %[2]s::vector::halfvec(%[1]d)",
+			o.dimension.Load(), param,
+		)
+	}
+	return "c.embedding <=> " + param
+}
+
+// setScanOptions tunes the index scan for the current transaction.
+// With iterative (pgvector >= 0.8) the scan keeps walking the index until
+// enough rows pass the filters; older versions do not know the setting, and
+// the larger ef_search over-fetches candidates instead.
+func (o *vectorIndex) setScanOptions(
+	ctx context.Context, tx pgx.Tx, limit int, scan VectorScan, iterative bool,
+) error {
+	if o.Type == "ivfflat" {
+		probes := o.IVFFlatProbes
+		if scan.Probes > 0 {
+			probes = scan.Probes
+		}
+		if iterative {
+			if _, err := tx.Exec(ctx, "SET LOCAL ivfflat.iterative_scan = relaxed_order"); err != nil {
+				return fmt.Errorf("set ivfflat.iterative_scan: %w", err)
+			}
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL ivfflat.probes = %d", probes)); err != nil {
+			return fmt.Errorf("set ivfflat.probes: %w", err)
+		}
+		return nil
+	}
+
+	efSearch := scan.EfSearch
+	if efSearch <= 0 {
+		efSearch = min(max(limit*vectorOverfetchFactor, minEfSearch), MaxEfSearch)
+	}
+	if iterative {
+		if _, err := tx.Exec(ctx, "SET LOCAL hnsw.iterative_scan = relaxed_order"); err != nil {
+			return fmt.Errorf("set hnsw.iterative_scan: %w", err)
+		}
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", efSearch)); err != nil {
+		return fmt.Errorf("set hnsw.ef_search: %w", err)
+	}
+	return nil
+}
+
+// scanSupport tells whether the installed pgvector (0.8 or later)
+// supports iterative index scans. The version is read once; when it cannot
+// be read the scan settings are left out.
+type scanSupport struct {
+	once      sync.Once
+	iterative bool
+}
+
+func (s *scanSupport) iterativeScan(ctx context.Context, db *pgxpool.Pool) bool {
+	s.once.Do(func() {
+		var version string
+		err := db.QueryRow(ctx,
+			`SELECT extversion FROM pg_extension WHERE extname = 'vector'`,
+		).Scan(&version)
+		if err != nil {
+			slog.Warn("failed to read pgvector version", "error", err)
+			return
+		}
+		var major, minor int
+		if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+			slog.Warn("unknown pgvector version", "version", version)
+			return
+		}
+		s.iterative = major > 0 || minor >= 8
+		if !s.iterative {
+			slog.Info("pgvector has no iterative index scans, filtered searches over-fetch instead",
+				"version", version)
+		}
+	})
+	return s.iterative
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"expvar"
 	"log/slog"
 	"net/http"
 	"time"
@@ -11,6 +12,7 @@ import (
 	_ "github.com/AndB0ndar/doc-archive/docs"
 	httpSwagger "github.com/swaggo/http-swagger"
 
+	"github.com/AndB0ndar/doc-archive/internal/config"
 	"github.com/AndB0ndar/doc-archive/internal/handlers"
 	mdwr "github.com/AndB0ndar/doc-archive/internal/middleware"
 	"github.com/AndB0ndar/doc-archive/internal/repository"
@@ -35,11 +37,18 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
+// Requests are cancelled after this, except those generating with the LLM
+const requestTimeout = 60 * time.Second
+
 func NewRouter(
+	cfg *config.Config,
 	userRepo *repository.UserRepository,
 	docRepo *repository.DocumentRepository,
 	docService *service.DocumentService,
 	searchService *service.SearchService,
+	answerService *service.AnswerService,
+	chatService *service.ChatService,
+	backfiller *service.Backfiller,
 ) http.Handler {
 	r := chi.NewRouter()
 
@@ -47,32 +56,64 @@ func NewRouter(
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.StripSlashes)
-	r.Use(middleware.Timeout(60 * time.Second))
 
 	r.Use(mdwr.Logger(slog.Default()))
 
 	authHandler := handlers.NewAuthHandler(userRepo)
 	uploadHandler := handlers.NewUploadHandler(docService)
 	searchAPIHandler := handlers.NewSearchHandler(searchService)
-	docHandler := handlers.NewDocumentHandler(docRepo) // FIXME
+	answerHandler := handlers.NewAnswerHandler(answerService)
+	chatHandler := handlers.NewChatHandler(chatService)
+	docHandler := handlers.NewDocumentHandler(docRepo, searchService) // FIXME
+	adminHandler := handlers.NewAdminHandler(backfiller)
+
+	timeout := middleware.Timeout(requestTimeout)
+	// Query rewriting and the answer each may take up to the LLM timeout
+	generationTimeout := middleware.Timeout(requestTimeout + 2*cfg.LLM.Timeout)
+
+	r.With(timeout).Get("/health", handlers.Health)
+
+	r.With(timeout).Post("/register", authHandler.Register)
+	r.With(timeout).Post("/login", authHandler.Login)
 
-	r.Get("/health", handlers.Health)
+	r.Group(func(r chi.Router) {
+		r.Use(mdwr.AuthMiddleware)
 
-	r.Post("/register", authHandler.Register)
-	r.Post("/login", authHandler.Login)
+		r.With(generationTimeout).Get("/answer", answerHandler.ServeHTTP)
+		r.With(generationTimeout).Post("/conversations/{id}/messages", chatHandler.PostMessage)
+		r.With(unlessStream(timeout)).Get("/search", searchAPIHandler.ServeHTTP)
+	})
 
 	r.Group(func(r chi.Router) {
+		r.Use(timeout)
 		r.Use(mdwr.AuthMiddleware)
 
 		r.Post("/upload", uploadHandler.ServeHTTP)
 
-		r.Get("/search", searchAPIHandler.ServeHTTP)
+		r.Post("/search/similar", searchAPIHandler.Similar)
 
 		r.Route("/documents", func(r chi.Router) {
 			r.Get("/", docHandler.ListDocuments)
 			r.Get("/{id}", docHandler.GetDocument)
+			r.Get("/{id}/similar", docHandler.SimilarDocuments)
+			r.Get("/{id}/search", docHandler.SearchDocument)
 			r.Delete("/{id}", docHandler.DeleteDocument)
 		})
+
+		r.Route("/conversations", func(r chi.Router) {
+			r.Get("/", chatHandler.ListConversations)
+			r.Post("/", chatHandler.CreateConversation)
+			r.Get("/{id}", chatHandler.GetConversation)
+			r.Delete("/{id}", chatHandler.DeleteConversation)
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Get("/backfill", adminHandler.BackfillStatus)
+			r.Post("/backfill", adminHandler.StartBackfill)
+		})
+
+		// Counters such as the embedding cache hit rates
+		r.With(mdwr.AdminOnly(cfg.IsAdmin)).Get("/debug/vars", expvar.Handler().ServeHTTP)
 	})
 
 	r.Get("/swagger/*", httpSwagger.Handler(
@@ -81,3 +122,18 @@ func NewRouter(
 
 	return r
 }
+
+// unlessStream applies timeout to all but streamed requests, which send
+// results stage by stage and end with the search or the client.
+func unlessStream(timeout func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limited := timeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if handlers.IsStream(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
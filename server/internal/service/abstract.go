@@ -0,0 +1,54 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// Pages searched for an abstract
+	abstractPages = 2
+	// Shorter text after the heading is taken for a mention, not an abstract
+	minAbstractRunes = 100
+)
+
+// Headings opening and closing an abstract. Extracted PDF text often has
+// no line breaks, so they are matched as words anywhere in the text.
+var (
+	abstractStart = regexp.MustCompile(
+		`(?i)(?:^|[^\p{L}])(?:abstract|аннотация|реферат)[\s.:—–-]*`,
+	)
+	abstractEnd = regexp.MustCompile(
+		`(?i)(?:^|[^\p{L}])(?:\d\.?\s*)?(?:introduction|keywords|key words|index terms|введение|ключевые слова)(?:[^\p{L}]|$)`,
+	)
+)
+
+// ExtractAbstract returns the abstract found on the first pages, or the
+// text of the first page without one, cut to maxRunes characters.
+func ExtractAbstract(pages []Page, maxRunes int) string {
+	if len(pages) == 0 {
+		return ""
+	}
+	text, _ := JoinPages(pages[:min(len(pages), abstractPages)])
+	if loc := abstractStart.FindStringIndex(text); loc != nil {
+		abstract := text[loc[1]:]
+		if end := abstractEnd.FindStringIndex(abstract); end != nil {
+			abstract = abstract[:end[0]]
+		}
+		if abstract = collapseSpaces(abstract); len([]rune(abstract)) >= minAbstractRunes {
+			return truncateRunes(abstract, maxRunes)
+		}
+	}
+	return truncateRunes(collapseSpaces(pages[0].Text), maxRunes)
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/AndB0ndar/doc-archive/internal/config"
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+var ErrLLM = fmt.Errorf("failed to generate answer")
+
+const answerSystemPrompt = `You are an assistant answering questions about the user's document archive.
+Answer using only the numbered context passages below. After every statement,
+cite the passages it is based on as [doc_id:chunk_index], for example [12:3].
+Use only references that appear in the context. If the context does not
+contain the answer, say that you could not find it in the documents.
+Answer in the language of the question.`
+
+type AnswerService struct {
+	cfg           *config.Config
+	searchService *SearchService
+	llm           LLM
+}
+
+func NewAnswerService(
+	cfg *config.Config,
+	searchService *SearchService,
+	llm LLM,
+) *AnswerService {
+	return &AnswerService{
+		cfg:           cfg,
+		searchService: searchService,
+		llm:           llm,
+	}
+}
+
+type AnswerRequest struct {
+	Question string
+	Type     string
+	UserID   int
+	Limit    int
+	Filters  models.SearchFilters
+	// Diversify the context passages, see MMR
+	MMR       bool
+	MMRLambda *float64
+}
+
+// Retrieve finds the context passages for the question.
+func (s *AnswerService) Retrieve(
+	ctx context.Context, req AnswerRequest,
+) ([]models.ChunkSearchResponse, error) {
+	if req.Type == "" {
+		req.Type = "semantic"
+	}
+	if req.Limit <= 0 || req.Limit > s.cfg.LLM.ContextChunks {
+		req.Limit = s.cfg.LLM.ContextChunks
+	}
+	return s.searchService.Search(ctx, SearchRequest{
+		Query:     req.Question,
+		Type:      req.Type,
+		UserID:    req.UserID,
+		Limit:     req.Limit,
+		Filters:   req.Filters,
+		MMR:       req.MMR,
+		MMRLambda: req.MMRLambda,
+	})
+}
+
+func (s *AnswerService) Answer(
+	ctx context.Context, req AnswerRequest,
+) (*models.AnswerResponse, error) {
+	sources, err := s.Retrieve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := s.llm.Complete(ctx, BuildAnswerPrompt(req.Question, sources))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLLM, err)
+	}
+	return newAnswerResponse(req.Question, text, sources), nil
+}
+
+// StreamAnswer generates the answer for already retrieved sources,
+// passing every piece of text to onDelta as it arrives.
+func (s *AnswerService) StreamAnswer(
+	ctx context.Context,
+	question string,
+	sources []models.ChunkSearchResponse,
+	onDelta func(string) error,
+) (*models.AnswerResponse, error) {
+	var builder strings.Builder
+	err := s.llm.Stream(ctx, BuildAnswerPrompt(question, sources), func(delta string) error {
+		builder.WriteString(delta)
+		return onDelta(delta)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLLM, err)
+	}
+	return newAnswerResponse(question, builder.String(), sources), nil
+}
+
+func BuildAnswerPrompt(
+	question string, sources []models.ChunkSearchResponse,
+) []LLMMessage {
+	var passages strings.Builder
+	for _, src := range sources {
+		fmt.Fprintf(&passages, "[%d:%d] %s", src.DocumentID, src.ChunkIndex, src.Title)
+		if src.Authors != nil {
+			fmt.Fprintf(&passages, ", %s", *src.Authors)
+		}
+		if src.Year != nil {
+			fmt.Fprintf(&passages, " (%d)", *src.Year)
+		}
+		passages.WriteString("\n")
+		passages.WriteString(strings.TrimSpace(src.Content))
+		passages.WriteString("\n\n")
+	}
+	if len(sources) == 0 {
+		passages.WriteString("(no passages found)\n\n")
+	}
+
+	return []LLMMessage{
+		{Role: "system", Content: answerSystemPrompt},
+		{
+			Role:    "user",
+			Content: "Context:\n\n" + passages.String() + "Question: " + question,
+		},
+	}
+}
+
+var citationGroupRe = regexp.MustCompile(`\[([0-9:,;\s]+)\]`)
+var citationRe = regexp.MustCompile(`(\d+)\s*:\s*(\d+)`)
+
+// ParseCitations returns the [doc_id:chunk_index] references of the answer
+// that point to one of the sources, in order of first appearance.
+func ParseCitations(
+	answer string, sources []models.ChunkSearchResponse,
+) []models.Citation {
+	type key struct{ doc, chunk int }
+	titles := make(map[key]string, len(sources))
+	for _, src := range sources {
+		titles[key{src.DocumentID, src.ChunkIndex}] = src.Title
+	}
+
+	seen := make(map[key]bool)
+	citations := []models.Citation{}
+	for _, group := range citationGroupRe.FindAllStringSubmatch(answer, -1) {
+		for _, m := range citationRe.FindAllStringSubmatch(group[1], -1) {
+			docID, _ := strconv.Atoi(m[1])
+			chunkIdx, _ := strconv.Atoi(m[2])
+			k := key{docID, chunkIdx}
+			title, ok := titles[k]
+			if !ok || seen[k] {
+				continue
+			}
+			seen[k] = true
+			citations = append(citations, models.Citation{
+				DocumentID: docID,
+				ChunkIndex: chunkIdx,
+				Title:      title,
+			})
+		}
+	}
+	return citations
+}
+
+func newAnswerResponse(
+	question, answer string, sources []models.ChunkSearchResponse,
+) *models.AnswerResponse {
+	if sources == nil {
+		sources = []models.ChunkSearchResponse{}
+	}
+	return &models.AnswerResponse{
+		Question:  question,
+		Answer:    strings.TrimSpace(answer),
+		Citations: ParseCitations(answer, sources),
+		Sources:   sources,
+	}
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/config"
+	"github.com/AndB0ndar/doc-archive/internal/models"
+	"github.com/AndB0ndar/doc-archive/internal/repository"
+)
+
+var (
+	ErrForbidden       = fmt.Errorf("admin access required")
+	ErrBackfillRunning = fmt.Errorf("backfill is already running")
+)
+
+// Documents younger than this may still be processed after upload and are
+// not taken for failed ones.
+const backfillDocumentGrace = 15 * time.Minute
+
+// Backfiller embeds what was left without an embedding: chunks whose
+// embedding failed, documents whose processing stored no chunks and
+// document vectors never averaged or built for search. It runs every
+// BACKFILL_INTERVAL and when an admin requests it.
+type Backfiller struct {
+	cfg        *config.Config
+	docRepo    *repository.DocumentRepository
+	chunkRepo  *repository.ChunkRepository
+	docService *DocumentService
+	embeddings *EmbeddingCache
+	trigger    chan struct{}
+
+	mu     sync.Mutex
+	status models.BackfillStatus
+}
+
+func NewBackfiller(
+	cfg *config.Config,
+	docRepo *repository.DocumentRepository,
+	chunkRepo *repository.ChunkRepository,
+	docService *DocumentService,
+	embeddings *EmbeddingCache,
+) *Backfiller {
+	return &Backfiller{
+		cfg:        cfg,
+		docRepo:    docRepo,
+		chunkRepo:  chunkRepo,
+		docService: docService,
+		embeddings: embeddings,
+		trigger:    make(chan struct{}, 1),
+	}
+}
+
+// Run backfills at start, then every BACKFILL_INTERVAL and whenever Start
+// asks for it, until ctx is done. A zero interval leaves only the
+// requested runs.
+func (b *Backfiller) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if b.cfg.Backfill.Interval > 0 {
+		ticker := time.NewTicker(b.cfg.Backfill.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+		b.Request()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-b.trigger:
+		}
+		b.run(ctx)
+	}
+}
+
+// Start requests a backfill on behalf of an admin.
+func (b *Backfiller) Start(userID int) error {
+	if !b.cfg.IsAdmin(userID) {
+		return ErrForbidden
+	}
+	if b.snapshot().Running {
+		return ErrBackfillRunning
+	}
+	b.Request()
+	return nil
+}
+
+// Request asks Run for a backfill, such as after a re-embedding cutover
+// cleared the document search vectors.
+func (b *Backfiller) Request() {
+	select {
+	case b.trigger <- struct{}{}:
+	default:
+		// Already requested
+	}
+}
+
+// Status returns the progress of the running or last backfill with the
+// current count of missing embeddings.
+func (b *Backfiller) Status(userID int) (models.BackfillStatus, error) {
+	if !b.cfg.IsAdmin(userID) {
+		return models.BackfillStatus{}, ErrForbidden
+	}
+	status := b.snapshot()
+	chunks, documents, err := b.chunkRepo.CountMissingEmbeddings()
+	if err != nil {
+		return models.BackfillStatus{}, err
+	}
+	status.MissingChunks, status.MissingDocuments = chunks, documents
+	return status, nil
+}
+
+func (b *Backfiller) snapshot() models.BackfillStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+func (b *Backfiller) update(f func(s *models.BackfillStatus)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f(&b.status)
+}
+
+func (b *Backfiller) run(ctx context.Context) {
+	started := time.Now()
+	b.update(func(s *models.BackfillStatus) {
+		*s = models.BackfillStatus{Running: true, StartedAt: &started}
+	})
+
+	err := b.backfill(ctx, started)
+
+	finished := time.Now()
+	b.update(func(s *models.BackfillStatus) {
+		s.Running = false
+		s.FinishedAt = &finished
+		if err != nil {
+			s.LastError = err.Error()
+		}
+	})
+	status := b.snapshot()
+	attrs := []any{
+		"documents_reprocessed", status.DocumentsReprocessed,
+		"chunks_embedded", status.ChunksEmbedded,
+		"chunks_failed", status.ChunksFailed,
+		"documents_updated", status.DocumentsUpdated,
+		"duration", finished.Sub(started),
+	}
+	if err != nil && ctx.Err() == nil {
+		slog.Error("backfill stopped", append(attrs, "error", err)...)
+		return
+	}
+	slog.Info("backfill finished", attrs...)
+}
+
+func (b *Backfiller) backfill(ctx context.Context, started time.Time) error {
+	cutoff := started.Add(-backfillDocumentGrace)
+	// Their chunks are stored unembedded and embedded by the chunk pass
+	if err := b.reprocessDocuments(ctx, cutoff); err != nil {
+		return err
+	}
+	wait := newRateLimit(b.cfg.Backfill.Rate)
+	defer wait.stop()
+	touched, err := b.embedChunks(ctx, wait)
+	if err != nil {
+		return err
+	}
+
+	ids, err := b.docRepo.MissingEmbedding()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		touched[id] = true
+	}
+	for id := range touched {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := b.docRepo.UpdateEmbedding(id); err != nil {
+			return err
+		}
+	}
+
+	// Search vectors blend in the centroid, so touched documents need new ones
+	ids, err = b.docRepo.MissingSearchEmbedding(cutoff)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		touched[id] = true
+	}
+	for id := range touched {
+		if err := wait.wait(ctx); err != nil {
+			return err
+		}
+		if err := b.docService.updateSearchEmbedding(ctx, id); err != nil {
+			if ctx.Err() != nil || errors.Is(err, errEmbedderUnavailable) {
+				return fmt.Errorf("document %d: %w", id, err)
+			}
+			slog.Warn("backfill failed to update document vector", "id", id, "error", err)
+			b.update(func(s *models.BackfillStatus) {
+				s.DocumentsFailed++
+				s.LastError = fmt.Sprintf("document %d: %v", id, err)
+			})
+			continue
+		}
+		b.update(func(s *models.BackfillStatus) { s.DocumentsUpdated++ })
+	}
+	return nil
+}
+
+// reprocessDocuments chunks again the documents created before cutoff
+// that have no chunks. Each is tried once: one that fails or yields no
+// chunks again is not taken by later runs.
+func (b *Backfiller) reprocessDocuments(ctx context.Context, cutoff time.Time) error {
+	after := 0
+	for {
+		docs, err := b.docRepo.WithoutChunks(cutoff, after, b.cfg.Backfill.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+		for _, doc := range docs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			after = doc.ID
+			chunkErr := b.docService.chunkDocument(doc.ID, doc.FilePath, false)
+			if err := b.docRepo.MarkReprocessed(doc.ID); err != nil {
+				return err
+			}
+			if err := chunkErr; err != nil {
+				b.update(func(s *models.BackfillStatus) {
+					s.DocumentsFailed++
+					s.LastError = fmt.Sprintf("document %d: %v", doc.ID, err)
+				})
+				continue
+			}
+			b.update(func(s *models.BackfillStatus) { s.DocumentsReprocessed++ })
+		}
+	}
+}
+
+// embedChunks embeds the chunks without an embedding at the rate limit and
+// returns the documents they belong to. Failed chunks are left to the next
+// run; it stops early when no embedder is available.
+func (b *Backfiller) embedChunks(ctx context.Context, limit *rateLimit) (map[int]bool, error) {
+	touched := make(map[int]bool)
+	var after int64
+	for {
+		chunks, err := b.chunkRepo.MissingEmbeddings(after, b.cfg.Backfill.BatchSize)
+		if err != nil {
+			return touched, err
+		}
+		if len(chunks) == 0 {
+			return touched, nil
+		}
+		for _, chunk := range chunks {
+			after = chunk.ID
+			if err := limit.wait(ctx); err != nil {
+				return touched, err
+			}
+
+			model := b.embeddings.Model()
+			embedding, err := b.embeddings.EmbedChunk(ctx, chunk.Content)
+			if err != nil {
+				if ctx.Err() != nil || errors.Is(err, errEmbedderUnavailable) {
+					return touched, fmt.Errorf("embed chunk %d: %w", chunk.ID, err)
+				}
+				slog.Warn("backfill failed to embed chunk", "chunk_id", chunk.ID, "error", err)
+				b.update(func(s *models.BackfillStatus) {
+					s.ChunksFailed++
+					s.LastError = fmt.Sprintf("chunk %d: %v", chunk.ID, err)
+				})
+				continue
+			}
+			if model != b.embeddings.Model() {
+				// A re-embedding cutover switched models, left to the next run
+				continue
+			}
+			stored, err := b.chunkRepo.SetEmbedding(chunk.ID, embedding, model)
+			if err != nil {
+				return touched, err
+			}
+			if stored {
+				touched[chunk.DocumentID] = true
+				b.update(func(s *models.BackfillStatus) { s.ChunksEmbedded++ })
+			}
+		}
+		status := b.snapshot()
+		slog.Info("backfill progress",
+			"chunks_embedded", status.ChunksEmbedded, "chunks_failed", status.ChunksFailed)
+	}
+}
+
+// rateLimit spaces calls evenly at rate per second, 0 is unlimited.
+type rateLimit struct {
+	ticker *time.Ticker
+}
+
+func newRateLimit(rate float64) *rateLimit {
+	if rate <= 0 {
+		return &rateLimit{}
+	}
+	// Rates above one per nanosecond would round the interval to zero
+	interval := max(time.Duration(float64(time.Second)/rate), time.Nanosecond)
+	return &rateLimit{ticker: time.NewTicker(interval)}
+}
+
+func (l *rateLimit) wait(ctx context.Context) error {
+	if l.ticker == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.ticker.C:
+		return nil
+	}
+}
+
+func (l *rateLimit) stop() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker takes an endpoint out after threshold consecutive
+// failures. After cooldown a single trial call is let through: success
+// closes the breaker, failure opens it for another cooldown.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time // zero while closed
+	probing  bool      // a trial call is in flight
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may be made now.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if time.Since(b.openedAt) < b.cooldown || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures, b.openedAt, b.probing = 0, time.Time{}, false
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.probing || b.failures >= b.threshold {
+		b.openedAt, b.probing = time.Now(), false
+	}
+}
+
+// release ends a call that tells nothing about the endpoint, such as one
+// cancelled by the caller.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// trip opens the breaker at once, e.g. when a health check fails.
+func (b *circuitBreaker) trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.openedAt, b.probing = time.Now(), false
+}
+
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.openedAt.IsZero()
+}
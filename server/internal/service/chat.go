@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AndB0ndar/doc-archive/internal/config"
+	"github.com/AndB0ndar/doc-archive/internal/models"
+	"github.com/AndB0ndar/doc-archive/internal/repository"
+)
+
+var (
+	ErrConversationNotFound = fmt.Errorf("conversation not found")
+	ErrEmptyMessage         = fmt.Errorf("empty message")
+)
+
+// Number of previous messages given to the model on every turn
+const chatHistoryMessages = 10
+
+const rewriteSystemPrompt = `Rewrite the user's last message into a standalone search query
+for a document archive, resolving pronouns and references using the conversation.
+Return only the query, without quotes or explanations.`
+
+type ChatService struct {
+	cfg           *config.Config
+	chatRepo      *repository.ChatRepository
+	searchService *SearchService
+	llm           LLM
+}
+
+func NewChatService(
+	cfg *config.Config,
+	chatRepo *repository.ChatRepository,
+	searchService *SearchService,
+	llm LLM,
+) *ChatService {
+	return &ChatService{
+		cfg:           cfg,
+		chatRepo:      chatRepo,
+		searchService: searchService,
+		llm:           llm,
+	}
+}
+
+func (s *ChatService) CreateConversation(
+	userID int, req models.CreateConversationRequest,
+) (*models.Conversation, error) {
+	conv := &models.Conversation{
+		Title:       strings.TrimSpace(req.Title),
+		DocumentIDs: req.DocumentIDs,
+		Categories:  req.Categories,
+		UserID:      userID,
+	}
+	if conv.Title == "" {
+		conv.Title = "New conversation"
+	}
+	if conv.DocumentIDs == nil {
+		conv.DocumentIDs = []int{}
+	}
+	if conv.Categories == nil {
+		conv.Categories = []string{}
+	}
+	if _, err := s.chatRepo.CreateConversation(conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+func (s *ChatService) ListConversations(
+	userID, limit, offset int,
+) ([]models.Conversation, error) {
+	return s.chatRepo.ListConversations(userID, limit, offset)
+}
+
+func (s *ChatService) GetConversation(
+	id int64, userID int,
+) (*models.ConversationWithMessages, error) {
+	conv, err := s.getConversation(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.chatRepo.ListMessages(conv.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ConversationWithMessages{
+		Conversation: *conv,
+		Messages:     messages,
+	}, nil
+}
+
+func (s *ChatService) DeleteConversation(id int64, userID int) error {
+	if _, err := s.getConversation(id, userID); err != nil {
+		return err
+	}
+	return s.chatRepo.DeleteConversation(id, userID)
+}
+
+// PostMessage runs one chat turn: rewrites the message into a standalone
+// query, retrieves the user's chunks and answers with citations.
+func (s *ChatService) PostMessage(
+	ctx context.Context,
+	conversationID int64,
+	userID int,
+	req models.PostMessageRequest,
+) (*models.PostMessageResponse, error) {
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		return nil, ErrEmptyMessage
+	}
+
+	conv, err := s.getConversation(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.chatRepo.ListMessages(conv.ID, chatHistoryMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := s.rewriteQuery(ctx, history, content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLLM, err)
+	}
+
+	filters := models.SearchFilters{
+		DocumentIDs: conv.DocumentIDs,
+		Categories:  conv.Categories,
+	}
+	if len(req.DocumentIDs) > 0 {
+		filters.DocumentIDs = req.DocumentIDs
+	}
+	if len(req.Categories) > 0 {
+		filters.Categories = req.Categories
+	}
+	sources, err := s.searchService.Search(ctx, SearchRequest{
+		Query:   query,
+		Type:    "semantic",
+		UserID:  userID,
+		Limit:   s.cfg.LLM.ContextChunks,
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prompt := BuildAnswerPrompt(content, sources)
+	messages := append([]LLMMessage{prompt[0]}, historyMessages(history)...)
+	messages = append(messages, prompt[1:]...)
+	text, err := s.llm.Complete(ctx, messages)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLLM, err)
+	}
+	answer := newAnswerResponse(content, text, sources)
+
+	question := models.ChatMessage{
+		ConversationID: conv.ID,
+		Role:           "user",
+		Content:        content,
+		SearchQuery:    &query,
+	}
+	reply := models.ChatMessage{
+		ConversationID: conv.ID,
+		Role:           "assistant",
+		Content:        answer.Answer,
+		Citations:      answer.Citations,
+	}
+	if err := s.chatRepo.AddExchange(&question, &reply); err != nil {
+		return nil, err
+	}
+
+	return &models.PostMessageResponse{
+		Question: question,
+		Answer:   reply,
+		Sources:  answer.Sources,
+	}, nil
+}
+
+// rewriteQuery turns a follow-up message into a standalone search query.
+// The first message of a conversation is used as is.
+func (s *ChatService) rewriteQuery(
+	ctx context.Context, history []models.ChatMessage, content string,
+) (string, error) {
+	if len(history) == 0 {
+		return content, nil
+	}
+
+	messages := []LLMMessage{{Role: "system", Content: rewriteSystemPrompt}}
+	messages = append(messages, historyMessages(history)...)
+	messages = append(messages, LLMMessage{Role: "user", Content: content})
+
+	query, err := s.llm.Complete(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	query = strings.Trim(strings.TrimSpace(query), `"'`)
+	if query == "" {
+		return content, nil
+	}
+	return query, nil
+}
+
+func (s *ChatService) getConversation(
+	id int64, userID int,
+) (*models.Conversation, error) {
+	conv, err := s.chatRepo.GetConversation(id, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrConversationNotFound
+	}
+	return conv, err
+}
+
+func historyMessages(history []models.ChatMessage) []LLMMessage {
+	messages := make([]LLMMessage, 0, len(history))
+	for _, m := range history {
+		messages = append(messages, LLMMessage{Role: m.Role, Content: m.Content})
+	}
+	return messages
+}
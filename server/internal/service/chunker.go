@@ -1,28 +1,45 @@
 package service
 
 func Chunk(text string, chunkSize, overlap int) []string {
-	if len(text) == 0 {
+	spans := ChunkSpans(text, chunkSize, overlap)
+	if spans == nil {
 		return nil
 	}
 	runes := []rune(text)
-	totalRunes := len(runes)
+	chunks := make([]string, 0, len(spans))
+	for _, s := range spans {
+		chunks = append(chunks, string(runes[s.Start:s.End]))
+	}
+	return chunks
+}
+
+// Span is a chunk of text, [Start, End) in characters.
+type Span struct {
+	Start, End int
+}
+
+// ChunkSpans returns the positions of the chunks Chunk cuts text into.
+func ChunkSpans(text string, chunkSize, overlap int) []Span {
+	if len(text) == 0 {
+		return nil
+	}
+	totalRunes := len([]rune(text))
 	if totalRunes <= chunkSize {
-		return []string{text}
+		return []Span{{0, totalRunes}}
 	}
 
-	var chunks []string
+	var spans []Span
 	start := 0
 	for start < totalRunes {
 		end := start + chunkSize
 		if end > totalRunes {
 			end = totalRunes
 		}
-		chunkRunes := runes[start:end]
-		chunks = append(chunks, string(chunkRunes))
+		spans = append(spans, Span{start, end})
 		start += chunkSize - overlap
 		if start < 0 {
 			start = 0
 		}
 	}
-	return chunks
+	return spans
 }
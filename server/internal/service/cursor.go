@@ -0,0 +1,40 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+var ErrInvalidCursor = fmt.Errorf("invalid cursor")
+
+// Cursors are opaque to clients: base64url-encoded JSON of the keyset
+// position, tied to the query, type and filters they were issued for.
+
+func encodeCursor(c models.SearchCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*models.SearchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c models.SearchCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ChunkID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// fingerprint identifies the result set of a request regardless of page.
+func (r *SearchRequest) fingerprint() uint64 {
+	h := fnv.New64a()
+	filters, _ := json.Marshal(r.Filters)
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00%s\x00%g", r.Type, r.Query, r.UserID, filters, r.MinScore)
+	return h.Sum64()
+}
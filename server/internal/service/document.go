@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
@@ -17,25 +18,25 @@ import (
 )
 
 type DocumentService struct {
-	cfg            *config.Config
-	docRepo        *repository.DocumentRepository
-	chunkRepo      *repository.ChunkRepository
-	embedderClient *Embedder
-	uploadDir      string
+	cfg        *config.Config
+	docRepo    *repository.DocumentRepository
+	chunkRepo  *repository.ChunkRepository
+	embeddings *EmbeddingCache
+	uploadDir  string
 }
 
 func NewDocumentService(
 	cfg *config.Config,
 	docRepo *repository.DocumentRepository,
 	chunkRepo *repository.ChunkRepository,
-	embedderClient *Embedder,
+	embeddings *EmbeddingCache,
 ) *DocumentService {
 	return &DocumentService{
-		cfg:            cfg,
-		docRepo:        docRepo,
-		chunkRepo:      chunkRepo,
-		embedderClient: embedderClient,
-		uploadDir:      cfg.UploadDir,
+		cfg:        cfg,
+		docRepo:    docRepo,
+		chunkRepo:  chunkRepo,
+		embeddings: embeddings,
+		uploadDir:  cfg.UploadDir,
 	}
 }
 
@@ -103,6 +104,7 @@ func (s *DocumentService) Upload(params *UploadParams) (int, error) {
 		Category: categoryPtr,
 		FilePath: fullPath,
 		FileSize: written,
+		Format:   "pdf",
 		UserID:   params.UserID,
 	}
 
@@ -119,36 +121,79 @@ func (s *DocumentService) Upload(params *UploadParams) (int, error) {
 	return id, nil
 }
 
+// Ingest stores doc for a PDF already on disk and processes it before
+// returning, for batch tools such as cmd/evaluate.
+func (s *DocumentService) Ingest(doc *models.Document) (int, error) {
+	if doc.Format == "" {
+		doc.Format = "pdf"
+	}
+	id, err := s.docRepo.Create(doc)
+	if err != nil {
+		return 0, fmt.Errorf("save metadata: %w", err)
+	}
+	s.processDocument(id, doc.FilePath)
+	return id, nil
+}
+
 func (s *DocumentService) processDocument(docID int, filePath string) {
+	if err := s.chunkDocument(docID, filePath, true); err != nil {
+		// TODO: can write error in database, in status field
+		return
+	}
+	if err := s.docRepo.UpdateEmbedding(docID); err != nil {
+		slog.Error("failed to update document embedding", "id", docID, "error", err)
+	}
+	if err := s.updateSearchEmbedding(context.Background(), docID); err != nil {
+		slog.Error("failed to update document search embedding", "id", docID, "error", err)
+	}
+	slog.Info("document chunks processed", "id", docID)
+}
+
+// chunkDocument extracts the text of the document and stores its chunks,
+// embedded when embed is set. Chunks whose embedding fails are stored
+// without one and picked up by the backfill.
+func (s *DocumentService) chunkDocument(docID int, filePath string, embed bool) error {
 	slog.Info("starting document processing", "id", docID, "path", filePath)
 
-	text, err := ExtractText(filePath)
+	pages, err := ExtractPages(filePath)
 	if err != nil {
 		slog.Error("failed to extract text from PDF", "id", docID, "error", err)
-		// TODO: can write error in database, in status field
-		return
+		return fmt.Errorf("extract text: %w", err)
 	}
+	if err := s.docRepo.SetAbstract(docID, ExtractAbstract(pages, s.cfg.ChunkSize)); err != nil {
+		slog.Error("failed to save document abstract", "id", docID, "error", err)
+	}
+	text, pageMap := JoinPages(pages)
+	runes := []rune(text)
 
 	chunkSize := s.cfg.ChunkSize
 	overlap := s.cfg.ChunkOverlap
-	chunks := Chunk(text, chunkSize, overlap)
-	slog.Info("text chunked", "id", docID, "chunks", len(chunks))
-
-	for idx, chunkText := range chunks {
-		embedding, err := s.embedderClient.Embed(chunkText)
-		if err != nil {
-			slog.Error("failed to get embedding for chunk", "doc_id", docID, "chunk_idx", idx, "error", err)
-			continue
-		}
+	spans := ChunkSpans(text, chunkSize, overlap)
+	slog.Info("text chunked", "id", docID, "chunks", len(spans))
+
+	for idx, span := range spans {
+		chunkText := string(runes[span.Start:span.End])
+		pageStart := pageMap.PageAt(span.Start)
+		pageEnd := pageMap.PageAt(span.End - 1)
 		chunk := &models.Chunk{
 			DocumentID: docID,
 			ChunkIndex: idx,
 			Content:    chunkText,
-			Embedding:  embedding,
+			PageStart:  &pageStart,
+			PageEnd:    &pageEnd,
+		}
+		if embed {
+			embedding, err := s.embeddings.EmbedChunk(context.Background(), chunkText)
+			if err != nil {
+				slog.Error("failed to get embedding for chunk", "doc_id", docID, "chunk_idx", idx, "error", err)
+			} else {
+				chunk.Embedding = embedding
+				chunk.EmbeddingModel = s.embeddings.Model()
+			}
 		}
 		if _, err := s.chunkRepo.Create(chunk); err != nil {
 			slog.Error("failed to save chunk", "doc_id", docID, "chunk_idx", idx, "error", err)
 		}
 	}
-	slog.Info("document chunks processed", "id", docID)
+	return nil
 }
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+)
+
+// Weights of the parts of a document search vector. Missing parts drop
+// out and the others keep their proportions.
+const (
+	metadataVectorWeight = 0.3 // title and authors
+	abstractVectorWeight = 0.3
+	centroidVectorWeight = 0.4 // mean of the chunk embeddings
+)
+
+type weightedVector struct {
+	vector []float32
+	weight float64
+}
+
+// updateSearchEmbedding builds the vector matched by type=document search
+// from the embeddings of the title and authors and of the abstract and
+// from the chunk centroid. Documents stored before abstracts were kept
+// get theirs extracted from the file first.
+func (s *DocumentService) updateSearchEmbedding(ctx context.Context, docID int) error {
+	src, err := s.docRepo.VectorSource(docID)
+	if err != nil {
+		return err
+	}
+	if src.Abstract == nil {
+		abstract := ""
+		if pages, err := ExtractPages(src.FilePath); err != nil {
+			slog.Warn("failed to extract abstract", "id", docID, "error", err)
+		} else {
+			abstract = ExtractAbstract(pages, s.cfg.ChunkSize)
+		}
+		if err := s.docRepo.SetAbstract(docID, abstract); err != nil {
+			return err
+		}
+		src.Abstract = &abstract
+	}
+
+	metadata := src.Title
+	if src.Authors != nil && *src.Authors != "" {
+		metadata += "\n" + *src.Authors
+	}
+	embedding, err := s.embeddings.EmbedChunk(ctx, metadata)
+	if err != nil {
+		return fmt.Errorf("embed title: %w", err)
+	}
+	parts := []weightedVector{{embedding, metadataVectorWeight}}
+	if *src.Abstract != "" {
+		embedding, err := s.embeddings.EmbedChunk(ctx, *src.Abstract)
+		if err != nil {
+			return fmt.Errorf("embed abstract: %w", err)
+		}
+		parts = append(parts, weightedVector{embedding, abstractVectorWeight})
+	}
+	if src.Centroid != nil {
+		parts = append(parts, weightedVector{src.Centroid, centroidVectorWeight})
+	}
+	combined, err := combineVectors(parts)
+	if err != nil {
+		return err
+	}
+	return s.docRepo.SetSearchEmbedding(docID, combined)
+}
+
+// combineVectors returns the unit-length weighted sum of the normalized
+// parts, so its cosine similarity to a query blends theirs. Parts that are
+// all zero or cancel out give no direction, and cosine distance to a zero
+// vector is undefined, so that is an error.
+func combineVectors(parts []weightedVector) ([]float32, error) {
+	sum := make([]float64, len(parts[0].vector))
+	for _, p := range parts {
+		var norm float64
+		for _, x := range p.vector {
+			norm += float64(x) * float64(x)
+		}
+		if norm == 0 || len(p.vector) != len(sum) {
+			continue
+		}
+		scale := p.weight / math.Sqrt(norm)
+		for i, x := range p.vector {
+			sum[i] += float64(x) * scale
+		}
+	}
+
+	var norm float64
+	for _, x := range sum {
+		norm += x * x
+	}
+	if norm == 0 {
+		return nil, fmt.Errorf("document vector parts are zero or cancel out")
+	}
+	combined := make([]float32, len(sum))
+	scale := 1 / math.Sqrt(norm)
+	for i, x := range sum {
+		combined[i] = float32(x * scale)
+	}
+	return combined, nil
+}
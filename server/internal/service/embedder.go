@@ -1,62 +1,46 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"time"
 
 	"github.com/AndB0ndar/doc-archive/internal/config"
 )
 
-type Embedder struct {
-	URL        string
-	httpClient *http.Client
-}
+var errEmbedderUnavailable = fmt.Errorf("%w: no embedder available", ErrEmbedding)
 
-func NewEmbedder(cfg *config.Config) *Embedder {
-	return &Embedder{
-		URL: cfg.EmbedderURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}
+// Embedder turns text into an embedding vector.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+	// Health checks the provider and returns the model it embeds with.
+	Health(ctx context.Context) (string, error)
+	// Model is the model reported by the last successful Health call.
+	Model() string
 }
 
-func (c *Embedder) Embed(text string) ([]float32, error) {
-	reqBody, err := json.Marshal(map[string][]string{
-		"texts": {text},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
-	}
-
-	resp, err := c.httpClient.Post(
-		c.URL+"/embed", "application/json", bytes.NewReader(reqBody),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("http post: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf(
-			"embedder returned status %d: %s", resp.StatusCode, string(body),
-		)
-	}
+// NewEmbedder returns the embedder selected by EMBEDDER_PROVIDER.
+func NewEmbedder(cfg *config.Config) (Embedder, error) {
+	return newEmbedder(cfg.Embedder)
+}
 
-	var response struct {
-		Embeddings [][]float32 `json:"embeddings"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+func newEmbedder(cfg config.EmbedderConfig) (Embedder, error) {
+	switch cfg.Provider {
+	case "bundled", "":
+		return newHTTPEmbedder(cfg, bundledAPI{}), nil
+	case "openai":
+		return newHTTPEmbedder(cfg, openAIAPI{model: cfg.Model, apiKey: cfg.APIKey}), nil
+	case "ollama":
+		return newHTTPEmbedder(cfg, ollamaAPI{model: cfg.Model}), nil
+	case "hash":
+		return NewHashEmbedder(cfg.Dimension), nil
 	}
+	return nil, fmt.Errorf("unknown embedding provider %q", cfg.Provider)
+}
 
-	if len(response.Embeddings) == 0 {
-		return nil, fmt.Errorf("no embedding returned")
+// MonitorEmbedder runs the background health checks of embedders that
+// have them until ctx is done.
+func MonitorEmbedder(ctx context.Context, embedder Embedder) {
+	if m, ok := embedder.(interface{ Monitor(context.Context) }); ok {
+		m.Monitor(ctx)
 	}
-	return response.Embeddings[0], nil
 }
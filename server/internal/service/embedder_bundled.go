@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// bundledAPI speaks the /embed and /health contract of the Python
+// embedder service in embedder/.
+type bundledAPI struct{}
+
+func (bundledAPI) embedRequest(ctx context.Context, url, text string) (*http.Request, error) {
+	return newJSONRequest(ctx, http.MethodPost, url+"/embed", map[string][]string{
+		"texts": {text},
+	})
+}
+
+func (bundledAPI) decodeEmbedding(body io.Reader) ([]float32, error) {
+	var response struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if len(response.Embeddings) == 0 {
+		return nil, nil
+	}
+	return response.Embeddings[0], nil
+}
+
+func (bundledAPI) healthRequest(ctx context.Context, url string) (*http.Request, error) {
+	return newJSONRequest(ctx, http.MethodGet, url+"/health", nil)
+}
+
+func (bundledAPI) decodeModel(body io.Reader) (string, error) {
+	var health struct {
+		Status     string `json:"status"`
+		EmbedModel string `json:"embed_model"`
+	}
+	if err := json.NewDecoder(body).Decode(&health); err != nil {
+		return "", fmt.Errorf("decode health: %w", err)
+	}
+	if health.EmbedModel == "" {
+		return "", fmt.Errorf("embedder health reports no embed_model")
+	}
+	return health.EmbedModel, nil
+}
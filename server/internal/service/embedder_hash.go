@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Character n-gram lengths hashed by the hash provider
+const (
+	minHashNgram = 3
+	maxHashNgram = 5
+)
+
+// hashEmbedder embeds in process by feature hashing: the character
+// n-grams of every word, padded with boundary marks, are hashed into a
+// signed bucket of the vector, which is then L2-normalized. It needs no
+// model or network and always returns the same vector for the same text,
+// so texts sharing words and word parts come out similar. Meant for
+// development, demos and tests, not for search quality.
+type hashEmbedder struct {
+	dimension int
+	model     string
+}
+
+// NewHashEmbedder returns the hash provider, also used by stand-in
+// embedders that need no model.
+func NewHashEmbedder(dimension int) Embedder {
+	return &hashEmbedder{
+		dimension: dimension,
+		model:     fmt.Sprintf("hash-char-ngram-%d-%d-d%d", minHashNgram, maxHashNgram, dimension),
+	}
+}
+
+func (e *hashEmbedder) Model() string {
+	return e.model
+}
+
+func (e *hashEmbedder) Health(context.Context) (string, error) {
+	return e.model, nil
+}
+
+func (e *hashEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	v := make([]float64, e.dimension)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		runes := []rune("<" + word + ">")
+		for n := minHashNgram; n <= maxHashNgram; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				e.add(v, string(runes[i:i+n]))
+			}
+		}
+	}
+
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	embedding := make([]float32, e.dimension)
+	if norm == 0 {
+		// Empty text, any fixed unit vector keeps cosine distance defined
+		embedding[0] = 1
+		return embedding, nil
+	}
+	scale := 1 / math.Sqrt(norm)
+	for i, x := range v {
+		embedding[i] = float32(x * scale)
+	}
+	return embedding, nil
+}
+
+// add hashes the feature into a bucket, the hash's top bit picks the
+// sign so collisions tend to cancel out.
+func (e *hashEmbedder) add(v []float64, feature string) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	sign := 1.0
+	if sum>>63 == 1 {
+		sign = -1
+	}
+	v[sum%uint64(e.dimension)] += sign
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestHashEmbedderDeterministic(t *testing.T) {
+	ctx := context.Background()
+	for _, dimension := range []int{8, 384, 1024} {
+		for _, text := range []string{"", "Go", "Goroutines and channels", "Горутины и каналы"} {
+			a, err := NewHashEmbedder(dimension).Embed(ctx, text)
+			if err != nil {
+				t.Fatalf("Embed(%q): %v", text, err)
+			}
+			b, err := NewHashEmbedder(dimension).Embed(ctx, text)
+			if err != nil {
+				t.Fatalf("Embed(%q): %v", text, err)
+			}
+			if len(a) != dimension {
+				t.Errorf("Embed(%q) has %d dimensions, want %d", text, len(a), dimension)
+			}
+			if !slices.Equal(a, b) {
+				t.Errorf("Embed(%q) differs between calls", text)
+			}
+			if norm := vectorNorm(a); math.Abs(norm-1) > 1e-5 {
+				t.Errorf("Embed(%q) has norm %f, want 1", text, norm)
+			}
+		}
+	}
+}
+
+func TestHashEmbedderSimilarity(t *testing.T) {
+	ctx := context.Background()
+	e := NewHashEmbedder(384)
+	embed := func(text string) []float32 {
+		v, err := e.Embed(ctx, text)
+		if err != nil {
+			t.Fatalf("Embed(%q): %v", text, err)
+		}
+		return v
+	}
+	query := embed("goroutine scheduling")
+	related := embed("the scheduler runs goroutines")
+	unrelated := embed("baking sourdough bread")
+	if cosineSimilarity(query, related) <= cosineSimilarity(query, unrelated) {
+		t.Errorf("texts sharing word parts are not closer: related %f, unrelated %f",
+			cosineSimilarity(query, related), cosineSimilarity(query, unrelated))
+	}
+}
+
+func vectorNorm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/config"
+)
+
+// Upper bound of the pause between retries
+const maxRetryBackoff = 5 * time.Second
+
+// embeddingAPI is the wire format of an embedding service.
+type embeddingAPI interface {
+	// embedRequest builds the request embedding text at the base url.
+	embedRequest(ctx context.Context, url, text string) (*http.Request, error)
+	// decodeEmbedding reads the vector from a successful embed response.
+	decodeEmbedding(body io.Reader) ([]float32, error)
+	healthRequest(ctx context.Context, url string) (*http.Request, error)
+	// decodeModel reads the served model from a successful health response.
+	decodeModel(body io.Reader) (string, error)
+}
+
+type embedderEndpoint struct {
+	url     string
+	breaker *circuitBreaker
+}
+
+// httpEmbedder calls embedding services over HTTP. Calls go round-robin
+// over the configured URLs, skip endpoints whose circuit breaker is open
+// and are retried with jittered backoff on 5xx responses and timeouts.
+type httpEmbedder struct {
+	cfg        config.EmbedderConfig
+	api        embeddingAPI
+	httpClient *http.Client
+	endpoints  []*embedderEndpoint
+	next       atomic.Uint64 // round-robin position
+
+	mu    sync.RWMutex
+	model string // reported by /health, empty until checked
+}
+
+func newHTTPEmbedder(cfg config.EmbedderConfig, api embeddingAPI) *httpEmbedder {
+	e := &httpEmbedder{
+		cfg: cfg,
+		api: api,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+	for _, url := range cfg.URLs {
+		e.endpoints = append(e.endpoints, &embedderEndpoint{
+			url:     url,
+			breaker: newCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		})
+	}
+	return e
+}
+
+func (c *httpEmbedder) Model() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.model
+}
+
+// Health asks every endpoint which model it serves and remembers it.
+// Endpoints that do not answer are taken out until they recover; the
+// others must agree on the model.
+func (c *httpEmbedder) Health(ctx context.Context) (string, error) {
+	var model string
+	var lastErr error
+	for _, ep := range c.endpoints {
+		m, err := c.healthAt(ctx, ep.url)
+		if err != nil {
+			slog.Warn("embedder is unhealthy", "url", ep.url, "error", err)
+			ep.breaker.trip()
+			lastErr = err
+			continue
+		}
+		if model != "" && m != model {
+			return "", fmt.Errorf(
+				"embedders serve different models: %q and %q (%s)", model, m, ep.url,
+			)
+		}
+		model = m
+	}
+	if model == "" {
+		return "", fmt.Errorf("no embedder is healthy: %w", lastErr)
+	}
+
+	c.mu.Lock()
+	c.model = model
+	c.mu.Unlock()
+	return model, nil
+}
+
+// Monitor checks the endpoints every HealthInterval until ctx is done:
+// failing ones are taken out, recovered ones return to the rotation.
+// An endpoint serving another model than the checked one stays out.
+func (c *httpEmbedder) Monitor(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		model := c.Model()
+		for _, ep := range c.endpoints {
+			m, err := c.healthAt(ctx, ep.url)
+			switch {
+			case err != nil:
+				if !ep.breaker.isOpen() {
+					slog.Warn("embedder is unhealthy", "url", ep.url, "error", err)
+				}
+				ep.breaker.trip()
+			case model != "" && m != model:
+				slog.Error("embedder serves another model",
+					"url", ep.url, "model", m, "expected", model)
+				ep.breaker.trip()
+			default:
+				if ep.breaker.isOpen() {
+					slog.Info("embedder recovered", "url", ep.url)
+				}
+				ep.breaker.success()
+			}
+		}
+	}
+}
+
+func (c *httpEmbedder) healthAt(ctx context.Context, url string) (string, error) {
+	req, err := c.api.healthRequest(ctx, url)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("http get: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf(
+			"embedder health returned status %d: %s", resp.StatusCode, string(body),
+		)
+	}
+	return c.api.decodeModel(resp.Body)
+}
+
+// pick returns the next endpoint whose breaker lets a call through.
+func (c *httpEmbedder) pick() *embedderEndpoint {
+	start := c.next.Add(1)
+	for i := range c.endpoints {
+		ep := c.endpoints[(start+uint64(i))%uint64(len(c.endpoints))]
+		if ep.breaker.allow() {
+			return ep
+		}
+	}
+	return nil
+}
+
+// Embed returns the embedding of text. It gives up when ctx is done and
+// fails fast with ErrEmbedding while every endpoint is taken out.
+func (c *httpEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	var lastErr error
+	for attempt := 0; attempt <= c.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, retryBackoff(c.cfg.RetryBackoff, attempt)); err != nil {
+				return nil, err
+			}
+		}
+		ep := c.pick()
+		if ep == nil {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w: %v", errEmbedderUnavailable, lastErr)
+			}
+			return nil, errEmbedderUnavailable
+		}
+
+		embedding, retry, err := c.embedAt(ctx, ep.url, text)
+		switch {
+		case err == nil:
+			ep.breaker.success()
+			return embedding, nil
+		case ctx.Err() != nil:
+			// The caller gave up, the endpoint is not to blame
+			ep.breaker.release()
+			return nil, ctx.Err()
+		case retry:
+			ep.breaker.failure()
+			lastErr = fmt.Errorf("%s: %w", ep.url, err)
+		default:
+			// The endpoint answered, the request itself is wrong
+			ep.breaker.success()
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// embedAt makes one embed call; retry reports whether another attempt
+// may succeed (network errors, timeouts, 429 and 5xx).
+func (c *httpEmbedder) embedAt(
+	ctx context.Context, url, text string,
+) (embedding []float32, retry bool, err error) {
+	req, err := c.api.embedRequest(ctx, url, text)
+	if err != nil {
+		return nil, false, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("http post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf(
+			"embedder returned status %d: %s", resp.StatusCode, string(body),
+		)
+	}
+
+	embedding, err = c.api.decodeEmbedding(resp.Body)
+	if err != nil {
+		var netErr interface{ Timeout() bool }
+		return nil, errors.As(err, &netErr) && netErr.Timeout(), err
+	}
+	if len(embedding) == 0 {
+		return nil, false, fmt.Errorf("no embedding returned")
+	}
+	return embedding, false, nil
+}
+
+// newJSONRequest builds a request with body encoded as JSON.
+func newJSONRequest(ctx context.Context, method, url string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// retryBackoff doubles base with every attempt up to maxRetryBackoff and
+// picks a random pause in its upper half, so retries do not align.
+func retryBackoff(base time.Duration, attempt int) time.Duration {
+	d := min(base<<(attempt-1), maxRetryBackoff)
+	return d/2 + rand.N(d/2+1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/config"
+)
+
+func testEmbedderConfig(provider, url string) config.EmbedderConfig {
+	return config.EmbedderConfig{
+		Provider:         provider,
+		URLs:             []string{url},
+		Timeout:          5 * time.Second,
+		Retries:          2,
+		RetryBackoff:     time.Millisecond,
+		BreakerThreshold: 5,
+		BreakerCooldown:  time.Second,
+		HealthInterval:   time.Second,
+	}
+}
+
+// stubRoute answers one method and path with a JSON response after
+// checking the decoded request body, when want is set.
+type stubRoute struct {
+	method, path string
+	want         map[string]any
+	response     any
+}
+
+func newStubServer(t *testing.T, header http.Header, routes ...stubRoute) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	for _, route := range routes {
+		mux.HandleFunc(route.method+" "+route.path, func(w http.ResponseWriter, r *http.Request) {
+			for name := range header {
+				if got := r.Header.Get(name); got != header.Get(name) {
+					t.Errorf("%s %s: header %s = %q, want %q",
+						route.method, route.path, name, got, header.Get(name))
+				}
+			}
+			if route.want != nil {
+				var body map[string]any
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("%s %s: decode request: %v", route.method, route.path, err)
+				}
+				if !reflect.DeepEqual(body, route.want) {
+					t.Errorf("%s %s: request %v, want %v", route.method, route.path, body, route.want)
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(route.response)
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPEmbedderProviders(t *testing.T) {
+	vector := []float32{0.25, -0.5, 1}
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+		apiKey   string
+		header   http.Header
+		routes   []stubRoute
+	}{
+		{
+			name:     "bundled",
+			provider: "bundled",
+			routes: []stubRoute{
+				{
+					method: http.MethodGet, path: "/health",
+					response: map[string]string{"status": "ok", "embed_model": "all-MiniLM-L6-v2"},
+				},
+				{
+					method: http.MethodPost, path: "/embed",
+					want:     map[string]any{"texts": []any{"hello world"}},
+					response: map[string]any{"embeddings": [][]float32{vector}},
+				},
+			},
+			model: "all-MiniLM-L6-v2",
+		},
+		{
+			name:     "openai",
+			provider: "openai",
+			model:    "text-embedding-3-small",
+			apiKey:   "secret",
+			header:   http.Header{"Authorization": {"Bearer secret"}},
+			routes: []stubRoute{
+				{
+					method: http.MethodGet, path: "/models",
+					response: map[string]any{"data": []map[string]string{
+						{"id": "gpt-4o"}, {"id": "text-embedding-3-small"},
+					}},
+				},
+				{
+					method: http.MethodPost, path: "/embeddings",
+					want: map[string]any{"model": "text-embedding-3-small", "input": "hello world"},
+					response: map[string]any{"data": []map[string]any{
+						{"embedding": vector, "index": 0},
+					}},
+				},
+			},
+		},
+		{
+			name:     "ollama",
+			provider: "ollama",
+			model:    "nomic-embed-text",
+			routes: []stubRoute{
+				{
+					method: http.MethodGet, path: "/api/tags",
+					response: map[string]any{"models": []map[string]string{
+						{"name": "nomic-embed-text:latest"},
+					}},
+				},
+				{
+					method: http.MethodPost, path: "/api/embed",
+					want:     map[string]any{"model": "nomic-embed-text", "input": "hello world"},
+					response: map[string]any{"embeddings": [][]float32{vector}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStubServer(t, tt.header, tt.routes...)
+			cfg := testEmbedderConfig(tt.provider, server.URL)
+			cfg.Model, cfg.APIKey = tt.model, tt.apiKey
+			embedder, err := newEmbedder(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			model, err := embedder.Health(ctx)
+			if err != nil {
+				t.Fatalf("Health: %v", err)
+			}
+			if model != tt.model || embedder.Model() != tt.model {
+				t.Errorf("model %q (Model() %q), want %q", model, embedder.Model(), tt.model)
+			}
+			got, err := embedder.Embed(ctx, "hello world")
+			if err != nil {
+				t.Fatalf("Embed: %v", err)
+			}
+			if !slices.Equal(got, vector) {
+				t.Errorf("Embed = %v, want %v", got, vector)
+			}
+		})
+	}
+}
+
+func TestHTTPEmbedderRetriesServerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // of the calls before a successful one
+		wantErr  bool
+		wantHits int32
+	}{
+		{"recovers after 5xx", []int{http.StatusServiceUnavailable, http.StatusBadGateway}, false, 3},
+		{"retries 429", []int{http.StatusTooManyRequests}, false, 2},
+		{"gives up after retries", []int{500, 500, 500, 500}, true, 3},
+		{"no retry on 4xx", []int{http.StatusBadRequest}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(hits.Add(1))
+				if n <= len(tt.statuses) {
+					http.Error(w, "failure", tt.statuses[n-1])
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]any{"embeddings": [][]float32{{1, 0}}})
+			}))
+			defer server.Close()
+
+			embedder, err := newEmbedder(testEmbedderConfig("bundled", server.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = embedder.Embed(context.Background(), "text")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Embed error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("%d calls, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestEmbeddingDimensionMismatch(t *testing.T) {
+	server := newStubServer(t, nil, stubRoute{
+		method: http.MethodPost, path: "/embed",
+		response: map[string]any{"embeddings": [][]float32{{1, 0, 0}}},
+	})
+	embedder, err := newEmbedder(testEmbedderConfig("bundled", server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := NewEmbeddingCache(embedder, 3, 0, nil).EmbedQuery(ctx, "text"); err != nil {
+		t.Errorf("matching dimension: %v", err)
+	}
+	_, err = NewEmbeddingCache(embedder, 4, 0, nil).EmbedQuery(ctx, "text")
+	if err == nil || !strings.Contains(err.Error(), "expected 4") {
+		t.Errorf("EmbedQuery error = %v, want a dimension mismatch", err)
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ollamaAPI speaks the Ollama embed API; the URL is the server address
+// such as http://localhost:11434.
+type ollamaAPI struct {
+	model string
+}
+
+func (a ollamaAPI) embedRequest(ctx context.Context, url, text string) (*http.Request, error) {
+	return newJSONRequest(ctx, http.MethodPost, url+"/api/embed", map[string]any{
+		"model": a.model,
+		"input": text,
+	})
+}
+
+func (ollamaAPI) decodeEmbedding(body io.Reader) ([]float32, error) {
+	var response struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if len(response.Embeddings) == 0 {
+		return nil, nil
+	}
+	return response.Embeddings[0], nil
+}
+
+// healthRequest lists the local models to check the model is pulled.
+func (ollamaAPI) healthRequest(ctx context.Context, url string) (*http.Request, error) {
+	return newJSONRequest(ctx, http.MethodGet, url+"/api/tags", nil)
+}
+
+func (a ollamaAPI) decodeModel(body io.Reader) (string, error) {
+	var response struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return "", fmt.Errorf("decode tags: %w", err)
+	}
+	for _, m := range response.Models {
+		// A model without a tag is pulled as name:latest
+		if m.Name == a.model || (!strings.Contains(a.model, ":") && m.Name == a.model+":latest") {
+			return a.model, nil
+		}
+	}
+	return "", fmt.Errorf("model %q is not pulled", a.model)
+}
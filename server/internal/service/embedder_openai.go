@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// openAIAPI speaks the OpenAI-compatible embeddings API; the URL is the
+// API base such as https://api.openai.com/v1.
+type openAIAPI struct {
+	model  string
+	apiKey string
+}
+
+func (a openAIAPI) embedRequest(ctx context.Context, url, text string) (*http.Request, error) {
+	req, err := newJSONRequest(ctx, http.MethodPost, url+"/embeddings", map[string]any{
+		"model": a.model,
+		"input": text,
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.authorize(req)
+	return req, nil
+}
+
+func (openAIAPI) decodeEmbedding(body io.Reader) ([]float32, error) {
+	var response struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if len(response.Data) == 0 {
+		return nil, nil
+	}
+	return response.Data[0].Embedding, nil
+}
+
+// healthRequest lists the models, which also checks the API key.
+func (a openAIAPI) healthRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := newJSONRequest(ctx, http.MethodGet, url+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	a.authorize(req)
+	return req, nil
+}
+
+func (a openAIAPI) decodeModel(body io.Reader) (string, error) {
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return "", fmt.Errorf("decode models: %w", err)
+	}
+	// Some compatible servers list no models, trust the configuration then
+	if len(response.Data) == 0 {
+		return a.model, nil
+	}
+	for _, m := range response.Data {
+		if m.ID == a.model {
+			return a.model, nil
+		}
+	}
+	return "", fmt.Errorf("model %q is not served", a.model)
+}
+
+func (a openAIAPI) authorize(req *http.Request) {
+	if a.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	}
+}
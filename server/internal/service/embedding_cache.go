@@ -0,0 +1,225 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/AndB0ndar/doc-archive/internal/repository"
+)
+
+// Hit and miss counters of the embedding caches, served to admins on
+// /debug/vars
+var embeddingCacheStats = expvar.NewMap("embedding_cache")
+
+func init() {
+	for _, kind := range []string{"query", "chunk"} {
+		embeddingCacheStats.Set(kind+"_hit_rate", expvar.Func(func() any {
+			hits := counterValue(kind + "_hits")
+			total := hits + counterValue(kind+"_misses")
+			if total == 0 {
+				return 0.0
+			}
+			return float64(hits) / float64(total)
+		}))
+	}
+}
+
+func counterValue(name string) int64 {
+	if v, ok := embeddingCacheStats.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// EmbeddingCache puts caches keyed by (model, text hash) in front of an
+// embedder: an in-process LRU for search queries and the embedding_cache
+// table for chunk texts. A model change makes the old entries unreachable,
+// Invalidate deletes them. It also checks the vector length, and a
+// re-embedding cutover switches it to the new embedder.
+type EmbeddingCache struct {
+	queries *lruCache // nil disables query caching
+	store   *repository.EmbeddingCacheRepository
+
+	mu        sync.RWMutex
+	embedder  Embedder
+	dimension int // expected vector length, 0 accepts any
+}
+
+func NewEmbeddingCache(
+	embedder Embedder,
+	dimension, querySize int,
+	store *repository.EmbeddingCacheRepository,
+) *EmbeddingCache {
+	c := &EmbeddingCache{embedder: embedder, dimension: dimension, store: store}
+	if querySize > 0 {
+		c.queries = newLRUCache(querySize)
+	}
+	return c
+}
+
+// Model is the model of the embedder behind the cache.
+func (c *EmbeddingCache) Model() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.embedder.Model()
+}
+
+func (c *EmbeddingCache) switchTo(embedder Embedder, dimension int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.embedder, c.dimension = embedder, dimension
+}
+
+func (c *EmbeddingCache) embed(ctx context.Context, text string) ([]float32, error) {
+	c.mu.RLock()
+	embedder, dimension := c.embedder, c.dimension
+	c.mu.RUnlock()
+
+	embedding, err := embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if dimension > 0 && len(embedding) != dimension {
+		return nil, fmt.Errorf(
+			"embedder returned %d dimensions, expected %d", len(embedding), dimension,
+		)
+	}
+	return embedding, nil
+}
+
+// Embed embeds text without caching it, for one-off texts such as the
+// passages of a similarity search that would only evict useful entries.
+func (c *EmbeddingCache) Embed(ctx context.Context, text string) ([]float32, error) {
+	return c.embed(ctx, text)
+}
+
+// EmbedQuery embeds a search query through the in-process cache.
+func (c *EmbeddingCache) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	model := c.Model()
+	if c.queries == nil || model == "" {
+		return c.embed(ctx, text)
+	}
+	key := cacheKey(model, text)
+	if embedding, ok := c.queries.get(model, key); ok {
+		embeddingCacheStats.Add("query_hits", 1)
+		return embedding, nil
+	}
+	embeddingCacheStats.Add("query_misses", 1)
+
+	embedding, err := c.embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	c.queries.add(model, key, embedding)
+	return embedding, nil
+}
+
+// EmbedChunk embeds a chunk text through the database cache. Cache
+// failures are logged and fall back to the embedder.
+func (c *EmbeddingCache) EmbedChunk(ctx context.Context, text string) ([]float32, error) {
+	model := c.Model()
+	if c.store == nil || model == "" {
+		return c.embed(ctx, text)
+	}
+	hash := sha256.Sum256([]byte(text))
+	embedding, err := c.store.Get(ctx, model, hash[:])
+	if err != nil {
+		slog.Warn("embedding cache lookup failed", "error", err)
+	}
+	if embedding != nil {
+		embeddingCacheStats.Add("chunk_hits", 1)
+		return embedding, nil
+	}
+	embeddingCacheStats.Add("chunk_misses", 1)
+
+	embedding, err = c.embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.store.Put(ctx, model, hash[:], embedding); err != nil {
+		slog.Warn("embedding cache store failed", "error", err)
+	}
+	return embedding, nil
+}
+
+// Invalidate deletes the cached chunk embeddings of every model but keep.
+func (c *EmbeddingCache) Invalidate(ctx context.Context, keep ...string) error {
+	if c.store == nil {
+		return nil
+	}
+	deleted, err := c.store.DeleteExcept(ctx, keep...)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		slog.Info("cached embeddings of other models deleted", "rows", deleted)
+	}
+	return nil
+}
+
+func cacheKey(model, text string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(model + "\x00" + text))
+}
+
+// lruCache keeps the most recently used embeddings of one model; entries
+// are dropped when another model is seen.
+type lruCache struct {
+	size int
+
+	mu    sync.Mutex
+	model string
+	order *list.List // front is the most recently used
+	items map[[sha256.Size]byte]*list.Element
+}
+
+type lruEntry struct {
+	key       [sha256.Size]byte
+	embedding []float32
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+func (c *lruCache) get(model string, key [sha256.Size]byte) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if model != c.model {
+		return nil, false
+	}
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).embedding, true
+}
+
+func (c *lruCache) add(model string, key [sha256.Size]byte, embedding []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if model != c.model {
+		c.model = model
+		c.order.Init()
+		clear(c.items)
+	}
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, embedding: embedding})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
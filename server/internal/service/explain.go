@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+	"github.com/AndB0ndar/doc-archive/internal/repository"
+)
+
+// searchTrace collects the timings of one explained search. It is shared
+// by pointer, so copies of the request report into the same trace.
+type searchTrace struct {
+	embedding time.Duration
+	rerank    time.Duration
+	query     repository.QueryTrace
+}
+
+func (t *searchTrace) queryTrace() *repository.QueryTrace {
+	if t == nil {
+		return nil
+	}
+	return &t.query
+}
+
+// explain attaches the search description to resp. Admins also get the
+// parsed query and SQL plan.
+func (s *SearchService) explain(
+	req SearchRequest, resp *models.SearchResponse, total time.Duration,
+) {
+	t := req.trace
+	resp.Explain = &models.SearchExplain{
+		Type:    req.Type,
+		Query:   req.Query,
+		Filters: s.describeFilters(req),
+		Timings: models.SearchTimings{
+			Embedding: milliseconds(t.embedding),
+			SQL:       milliseconds(t.query.Duration),
+			Rerank:    milliseconds(t.rerank),
+			Total:     milliseconds(total),
+		},
+	}
+	if !t.query.WithPlan {
+		return
+	}
+	if req.parsed != nil {
+		resp.Explain.ParsedQuery = &models.ParsedQuery{
+			Text:    req.parsed.Text,
+			Phrases: req.parsed.Phrases,
+			Exclude: req.parsed.Exclude,
+			Filters: req.parsed.Filters,
+		}
+	}
+	resp.Explain.SQL = t.query.SQL
+	resp.Explain.Plan = t.query.Plan
+}
+
+// explainResults attaches the raw score of every result.
+func explainResults(results []models.ChunkSearchResponse, searchType string) {
+	for i := range results {
+		r := &results[i]
+		if r.Explain == nil {
+			r.Explain = &models.ResultExplain{}
+		}
+		score := r.Similarity
+		if searchType == "text" {
+			r.Explain.TrigramScore = &score
+		} else {
+			distance := 1 - score
+			r.Explain.CosineDistance = &distance
+		}
+	}
+}
+
+// describeFilters lists the conditions the search applied, in the
+// order the repository adds them.
+func (s *SearchService) describeFilters(req SearchRequest) []string {
+	f := req.Filters
+	filters := []string{}
+	add := func(format string, args ...any) {
+		filters = append(filters, fmt.Sprintf(format, args...))
+	}
+
+	switch req.Type {
+	case "text":
+		add("word_similarity >= %g (pg_trgm threshold)", s.cfg.Database.TextSimilarityThreshold)
+	case "document":
+		add("document has a search vector")
+	default:
+		add("chunk has an embedding")
+	}
+	if len(f.DocumentIDs) > 0 {
+		add("document_id in %v", f.DocumentIDs)
+	}
+	if len(f.Categories) > 0 {
+		add("category in [%s]", strings.Join(f.Categories, ", "))
+	}
+	if f.YearFrom != nil {
+		add("year >= %d", *f.YearFrom)
+	}
+	if f.YearTo != nil {
+		add("year <= %d", *f.YearTo)
+	}
+	if f.Author != "" {
+		add("authors contain %q", f.Author)
+	}
+	if f.UploadedFrom != nil {
+		add("uploaded >= %s", f.UploadedFrom.Format(time.RFC3339))
+	}
+	if f.UploadedTo != nil {
+		add("uploaded <= %s", f.UploadedTo.Format(time.RFC3339))
+	}
+	if len(f.Formats) > 0 {
+		add("format in [%s]", strings.Join(f.Formats, ", "))
+	}
+	for _, p := range f.Phrases {
+		add("content contains %q", p)
+	}
+	for _, e := range f.Exclude {
+		add("content does not contain %q", e)
+	}
+	if req.MinScore > 0 {
+		add("score >= %g", req.MinScore)
+	}
+	if req.after != nil {
+		add("after cursor (score %g, chunk %d)", req.after.Score, req.after.ChunkID)
+	}
+	return filters
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+const (
+	defaultGroupSize = 3
+	maxGroupSize     = 10
+
+	// Chunks fetched per requested document when grouping
+	groupOverfetchFactor = 5
+	maxGroupCandidates   = 500
+)
+
+// GroupByDocument aggregates results sorted by similarity into at most
+// limit documents, each with up to groupSize supporting chunks. A chunk
+// adjacent to an already selected one of the same document shares its
+// overlap and is skipped. CandidateHits counts a document's chunks among
+// results only, so it depends on how many candidates were fetched.
+func GroupByDocument(
+	results []models.ChunkSearchResponse, limit, groupSize int,
+) []models.DocumentSearchResult {
+	var groups []*models.DocumentSearchResult
+	byDoc := make(map[int]*models.DocumentSearchResult)
+
+	for _, r := range results {
+		g, ok := byDoc[r.DocumentID]
+		if !ok {
+			g = &models.DocumentSearchResult{
+				DocumentID: r.DocumentID,
+				Title:      r.Title,
+				Authors:    r.Authors,
+				Year:       r.Year,
+				Category:   r.Category,
+				Score:      r.Similarity,
+			}
+			byDoc[r.DocumentID] = g
+			groups = append(groups, g)
+		}
+		g.CandidateHits++
+		if len(g.Chunks) < groupSize && !hasAdjacentChunk(g.Chunks, r.ChunkIndex) {
+			g.Chunks = append(g.Chunks, r)
+		}
+	}
+
+	if len(groups) > limit {
+		groups = groups[:limit]
+	}
+	grouped := make([]models.DocumentSearchResult, 0, len(groups))
+	for _, g := range groups {
+		grouped = append(grouped, *g)
+	}
+	return grouped
+}
+
+func hasAdjacentChunk(chunks []models.ChunkSearchResponse, index int) bool {
+	for _, c := range chunks {
+		if d := c.ChunkIndex - index; d >= -1 && d <= 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+const (
+	defaultFragmentSize = 200
+	minFragmentSize     = 40
+	maxFragmentSize     = 1000
+	defaultFragments    = 3
+	maxFragments        = 10
+
+	// Content words sharing at least this many leading runes with a query
+	// term count as matches, so "indexes" highlights "indexing".
+	minPrefixMatch = 5
+)
+
+type HighlightOptions struct {
+	FragmentSize int
+	Fragments    int
+}
+
+func (o *HighlightOptions) normalize() {
+	if o.FragmentSize <= 0 {
+		o.FragmentSize = defaultFragmentSize
+	}
+	o.FragmentSize = min(max(o.FragmentSize, minFragmentSize), maxFragmentSize)
+	if o.Fragments <= 0 {
+		o.Fragments = defaultFragments
+	}
+	o.Fragments = min(o.Fragments, maxFragments)
+}
+
+// Highlight fills the matched ranges and snippets of every result.
+// Offsets are in characters (Unicode code points) of the chunk content.
+func Highlight(
+	results []models.ChunkSearchResponse,
+	query string,
+	phrases []string,
+	opts HighlightOptions,
+) {
+	opts.normalize()
+	terms := highlightTerms(query)
+	for i := range results {
+		content := []rune(results[i].Content)
+		matches := FindMatches(content, terms, phrases)
+		results[i].Matches = matches
+		results[i].Snippets = buildSnippets(content, matches, opts)
+	}
+}
+
+func highlightTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(query), isWordSeparator) {
+		if len([]rune(w)) < 2 || seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// FindMatches returns the sorted, non-overlapping ranges of content that
+// match a query term (whole word or shared prefix) or a phrase.
+func FindMatches(
+	content []rune, terms, phrases []string,
+) []models.MatchRange {
+	// Lower-case rune by rune to keep offsets aligned with content
+	lower := make([]rune, len(content))
+	for i, r := range content {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var ranges []models.MatchRange
+	for start := 0; start < len(lower); {
+		if isWordSeparator(lower[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(lower) && !isWordSeparator(lower[end]) {
+			end++
+		}
+		if wordMatches(lower[start:end], terms) {
+			ranges = append(ranges, models.MatchRange{Start: start, End: end})
+		}
+		start = end
+	}
+
+	for _, phrase := range phrases {
+		p := []rune(strings.Map(unicode.ToLower, phrase))
+		if len(p) == 0 {
+			continue
+		}
+		for i := 0; i+len(p) <= len(lower); i++ {
+			if string(lower[i:i+len(p)]) == string(p) {
+				ranges = append(ranges, models.MatchRange{Start: i, End: i + len(p)})
+				i += len(p) - 1
+			}
+		}
+	}
+	return mergeRanges(ranges)
+}
+
+func wordMatches(word []rune, terms []string) bool {
+	for _, term := range terms {
+		t := []rune(term)
+		if string(word) == term {
+			return true
+		}
+		n := 0
+		for n < len(word) && n < len(t) && word[n] == t[n] {
+			n++
+		}
+		if n >= minPrefixMatch && n >= len(t)-3 {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeRanges(ranges []models.MatchRange) []models.MatchRange {
+	if len(ranges) == 0 {
+		return []models.MatchRange{}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	merged := []models.MatchRange{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			last.End = max(last.End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// buildSnippets picks up to opts.Fragments windows with the most matches,
+// returned in document order. Without matches the chunk start is used.
+func buildSnippets(
+	content []rune, matches []models.MatchRange, opts HighlightOptions,
+) []models.Snippet {
+	size := opts.FragmentSize
+	if len(matches) == 0 {
+		end := min(size, len(content))
+		return []models.Snippet{newSnippet(content, 0, end, nil)}
+	}
+
+	type window struct{ start, end, hits int }
+	var windows []window
+	for _, m := range matches {
+		start := m.Start - (size-(m.End-m.Start))/2
+		start = max(0, min(start, len(content)-size))
+		end := min(start+size, len(content))
+		hits := 0
+		for _, o := range matches {
+			if o.Start >= start && o.End <= end {
+				hits++
+			}
+		}
+		windows = append(windows, window{start, end, hits})
+	}
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].hits > windows[j].hits })
+
+	var picked []window
+	for _, w := range windows {
+		overlaps := false
+		for _, p := range picked {
+			if w.start < p.end && p.start < w.end {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			picked = append(picked, w)
+		}
+		if len(picked) == opts.Fragments {
+			break
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].start < picked[j].start })
+
+	snippets := make([]models.Snippet, 0, len(picked))
+	for _, w := range picked {
+		snippets = append(snippets, newSnippet(content, w.start, w.end, matches))
+	}
+	return snippets
+}
+
+// newSnippet renders content[start:end] as HTML-escaped text with
+// matches wrapped in <mark> tags.
+func newSnippet(
+	content []rune, start, end int, matches []models.MatchRange,
+) models.Snippet {
+	var b strings.Builder
+	pos := start
+	for _, m := range matches {
+		if m.End <= start || m.Start >= end {
+			continue
+		}
+		ms, me := max(m.Start, start), min(m.End, end)
+		b.WriteString(html.EscapeString(string(content[pos:ms])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(content[ms:me])))
+		b.WriteString("</mark>")
+		pos = me
+	}
+	b.WriteString(html.EscapeString(string(content[pos:end])))
+
+	return models.Snippet{
+		Text:  strings.TrimSpace(b.String()),
+		Start: start,
+		End:   end,
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/AndB0ndar/doc-archive/internal/config"
+)
+
+// LLMMessage is a single message of a chat-completions conversation.
+type LLMMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// LLM generates text from a list of chat messages.
+type LLM interface {
+	Complete(ctx context.Context, messages []LLMMessage) (string, error)
+	// Stream calls onDelta for every generated piece of text.
+	Stream(ctx context.Context, messages []LLMMessage, onDelta func(string) error) error
+}
+
+// OpenAIChat talks to any server implementing the OpenAI
+// /chat/completions API (OpenAI, vLLM, llama.cpp, Ollama, ...).
+type OpenAIChat struct {
+	URL         string
+	Model       string
+	apiKey      string
+	temperature float64
+	maxTokens   int
+	httpClient  *http.Client
+}
+
+func NewOpenAIChat(cfg *config.Config) *OpenAIChat {
+	return &OpenAIChat{
+		URL:         strings.TrimRight(cfg.LLM.URL, "/"),
+		Model:       cfg.LLM.Model,
+		apiKey:      cfg.LLM.APIKey,
+		temperature: cfg.LLM.Temperature,
+		maxTokens:   cfg.LLM.MaxTokens,
+		httpClient: &http.Client{
+			Timeout: cfg.LLM.Timeout,
+		},
+	}
+}
+
+type chatCompletionRequest struct {
+	Model       string       `json:"model"`
+	Messages    []LLMMessage `json:"messages"`
+	Temperature float64      `json:"temperature"`
+	MaxTokens   int          `json:"max_tokens,omitempty"`
+	Stream      bool         `json:"stream"`
+}
+
+func (c *OpenAIChat) Complete(
+	ctx context.Context, messages []LLMMessage,
+) (string, error) {
+	resp, err := c.post(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Choices []struct {
+			Message LLMMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("no choices returned")
+	}
+	return response.Choices[0].Message.Content, nil
+}
+
+func (c *OpenAIChat) Stream(
+	ctx context.Context, messages []LLMMessage, onDelta func(string) error,
+) error {
+	resp, err := c.post(ctx, messages, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta LLMMessage `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("decode stream chunk: %w", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		if err := onDelta(chunk.Choices[0].Delta.Content); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	return nil
+}
+
+func (c *OpenAIChat) post(
+	ctx context.Context, messages []LLMMessage, stream bool,
+) (*http.Response, error) {
+	reqBody, err := json.Marshal(chatCompletionRequest{
+		Model:       c.Model,
+		Messages:    messages,
+		Temperature: c.temperature,
+		MaxTokens:   c.maxTokens,
+		Stream:      stream,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, c.URL+"/chat/completions", bytes.NewReader(reqBody),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http post: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf(
+			"llm returned status %d: %s", resp.StatusCode, string(body),
+		)
+	}
+	return resp, nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"math"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+const (
+	DefaultMMRLambda = 0.5
+
+	// Candidates fetched per requested result for MMR
+	mmrOverfetchFactor = 4
+	maxMMRCandidates   = 400
+)
+
+// mmrSelect selects k candidates by maximal marginal relevance:
+//
+//	lambda * sim(query, d) - (1 - lambda) * max sim(d, selected)
+//
+// and returns them with the score each was picked with. Candidates carry
+// their query similarity and embedding. Lambda 1 keeps the relevance
+// order, lower values favour diversity.
+func mmrSelect(
+	candidates []models.ChunkSearchResponse, k int, lambda float64,
+) ([]models.ChunkSearchResponse, []float64) {
+	if k >= len(candidates) {
+		k = len(candidates)
+	}
+	selected := make([]models.ChunkSearchResponse, 0, k)
+	scores := make([]float64, 0, k)
+	// maxSim[i] is the highest similarity of candidate i to the selection
+	maxSim := make([]float64, len(candidates))
+	used := make([]bool, len(candidates))
+
+	for len(selected) < k {
+		best, bestScore := -1, math.Inf(-1)
+		for i, c := range candidates {
+			if used[i] {
+				continue
+			}
+			score := lambda * c.Similarity
+			if len(selected) > 0 {
+				score -= (1 - lambda) * maxSim[i]
+			}
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		used[best] = true
+		chosen := candidates[best]
+		selected = append(selected, chosen)
+		scores = append(scores, bestScore)
+		for i, c := range candidates {
+			if used[i] {
+				continue
+			}
+			if sim := cosineSimilarity(c.Embedding, chosen.Embedding); sim > maxSim[i] {
+				maxSim[i] = sim
+			}
+		}
+	}
+	return selected, scores
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
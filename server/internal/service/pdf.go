@@ -2,19 +2,38 @@ package service
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strings"
 
 	"github.com/ledongthuc/pdf"
 )
 
+// Page is the plain text of one PDF page, Number is 1-based.
+type Page struct {
+	Number int
+	Text   string
+}
+
 func ExtractText(filePath string) (string, error) {
+	pages, err := ExtractPages(filePath)
+	if err != nil {
+		return "", err
+	}
+	text, _ := JoinPages(pages)
+	return text, nil
+}
+
+// ExtractPages returns the text of every page with extractable text.
+func ExtractPages(filePath string) ([]Page, error) {
 	f, r, err := pdf.Open(filePath)
 	if err != nil {
-		return "", fmt.Errorf("open pdf: %w", err)
+		return nil, fmt.Errorf("open pdf: %w", err)
 	}
 	defer f.Close()
 
-	var builder strings.Builder
+	var pages []Page
 	for i := 1; i <= r.NumPage(); i++ {
 		p := r.Page(i)
 		if p.V.IsNull() {
@@ -25,13 +44,57 @@ func ExtractText(filePath string) (string, error) {
 			continue
 		}
 		//cleanText := strings.ToValidUTF8(text, " ")
-		//builder.WriteString(cleanText)
-		builder.WriteString(text)
+		pages = append(pages, Page{Number: i, Text: text})
+	}
+
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no text could be extracted from PDF")
+	}
+	return pages, nil
+}
+
+// JoinPages concatenates the pages, each followed by a newline, and
+// returns the text with a map from character offsets to page numbers.
+func JoinPages(pages []Page) (string, *PageMap) {
+	var builder strings.Builder
+	m := &PageMap{}
+	offset := 0
+	for _, p := range pages {
+		m.starts = append(m.starts, offset)
+		m.numbers = append(m.numbers, p.Number)
+		builder.WriteString(p.Text)
 		builder.WriteString("\n")
+		offset += len([]rune(p.Text)) + 1
+	}
+	return builder.String(), m
+}
+
+// PageMap locates character offsets of joined page text.
+type PageMap struct {
+	starts  []int // offset of the first character of each page
+	numbers []int
+}
+
+// PageAt returns the page number containing the character at offset.
+func (m *PageMap) PageAt(offset int) int {
+	if len(m.starts) == 0 {
+		return 0
+	}
+	i := sort.Search(len(m.starts), func(i int) bool { return m.starts[i] > offset })
+	return m.numbers[max(i-1, 0)]
+}
+
+// ExtractTextFrom extracts the text of a PDF that is not stored on disk.
+func ExtractTextFrom(r io.Reader) (string, error) {
+	tmp, err := os.CreateTemp("", "doc-archive-*.pdf")
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
 	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
 
-	if builder.Len() == 0 {
-		return "", fmt.Errorf("no text could be extracted from PDF")
+	if _, err := io.Copy(tmp, r); err != nil {
+		return "", fmt.Errorf("copy pdf: %w", err)
 	}
-	return builder.String(), nil
+	return ExtractText(tmp.Name())
 }
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+)
+
+var ErrQuerySyntax = errors.New("query syntax error")
+
+// QuerySyntaxError reports a malformed search query. Pos is the 0-based
+// character offset of the problem in the query string.
+type QuerySyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+func (e *QuerySyntaxError) Unwrap() error {
+	return ErrQuerySyntax
+}
+
+// Query is the result of parsing the /search query language:
+//
+//	author:pike year:>=2010 category:go "memory model" -python
+//
+// Bare words and quoted phrases form the ranked text, phrases must also
+// occur literally. A leading '-' excludes a word or phrase. Qualifiers are
+// field:value, field:"quoted value", field:<op>value with >, >=, <, <=, =
+// for year and uploaded, and field:from..to ranges.
+type Query struct {
+	Text    string
+	Phrases []string
+	Exclude []string
+	Filters models.SearchFilters
+
+	qualified bool // has qualifiers or exclusions
+}
+
+type queryField int
+
+const (
+	fieldAuthor queryField = iota
+	fieldCategory
+	fieldYear
+	fieldFormat
+	fieldDocument
+	fieldUploaded
+)
+
+var queryFields = map[string]queryField{
+	"author":   fieldAuthor,
+	"authors":  fieldAuthor,
+	"category": fieldCategory,
+	"year":     fieldYear,
+	"format":   fieldFormat,
+	"doc":      fieldDocument,
+	"id":       fieldDocument,
+	"uploaded": fieldUploaded,
+}
+
+// ParseQuery parses the query language. Words that look like qualifiers
+// but name an unknown field (e.g. "c++:") are kept as plain text.
+func ParseQuery(input string) (*Query, error) {
+	p := &queryParser{src: []rune(input)}
+	q := &Query{}
+	var terms []string
+
+	for {
+		p.skipSpaces()
+		if p.eof() {
+			break
+		}
+		start := p.pos
+
+		negate := false
+		if p.peek() == '-' {
+			p.pos++
+			// A lone dash ("Go - intro") is punctuation, not an exclusion
+			if p.eof() || unicode.IsSpace(p.peek()) {
+				continue
+			}
+			negate = true
+		}
+
+		if p.peek() == '"' {
+			phrase, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			if phrase == "" {
+				continue
+			}
+			if negate {
+				q.Exclude = append(q.Exclude, phrase)
+				q.qualified = true
+			} else {
+				q.Phrases = append(q.Phrases, phrase)
+				terms = append(terms, phrase)
+			}
+			continue
+		}
+
+		word := p.word()
+		if name, rest, ok := strings.Cut(word, ":"); ok {
+			if field, known := queryFields[strings.ToLower(name)]; known {
+				if negate {
+					return nil, p.errorf(start, "qualifier %q cannot be negated", name)
+				}
+				valuePos := start + len([]rune(name)) + 1
+				value := rest
+				if rest == "" && !p.eof() && p.peek() == '"' {
+					quoted, err := p.quoted()
+					if err != nil {
+						return nil, err
+					}
+					value = quoted
+				}
+				if err := p.applyField(q, field, name, value, valuePos); err != nil {
+					return nil, err
+				}
+				q.qualified = true
+				continue
+			}
+		}
+
+		if negate {
+			q.Exclude = append(q.Exclude, word)
+			q.qualified = true
+		} else {
+			terms = append(terms, word)
+		}
+	}
+
+	q.Text = strings.Join(terms, " ")
+	return q, nil
+}
+
+// Apply sets the ranked text of req and adds the parsed filters
+// to the ones already present.
+func (q *Query) Apply(req *SearchRequest) {
+	req.parsed = q
+	req.Query = q.Text
+	f := &req.Filters
+	f.Phrases = append(f.Phrases, q.Phrases...)
+	f.Exclude = append(f.Exclude, q.Exclude...)
+	f.DocumentIDs = append(f.DocumentIDs, q.Filters.DocumentIDs...)
+	f.Categories = append(f.Categories, q.Filters.Categories...)
+	f.Formats = append(f.Formats, q.Filters.Formats...)
+	if q.Filters.Author != "" {
+		f.Author = q.Filters.Author
+	}
+	if q.Filters.YearFrom != nil {
+		f.YearFrom = q.Filters.YearFrom
+	}
+	if q.Filters.YearTo != nil {
+		f.YearTo = q.Filters.YearTo
+	}
+	if q.Filters.UploadedFrom != nil {
+		f.UploadedFrom = q.Filters.UploadedFrom
+	}
+	if q.Filters.UploadedTo != nil {
+		f.UploadedTo = q.Filters.UploadedTo
+	}
+}
+
+type queryParser struct {
+	src []rune
+	pos int
+}
+
+func (p *queryParser) eof() bool  { return p.pos >= len(p.src) }
+func (p *queryParser) peek() rune { return p.src[p.pos] }
+
+func (p *queryParser) skipSpaces() {
+	for !p.eof() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+// word reads up to the next space or quote.
+func (p *queryParser) word() string {
+	start := p.pos
+	for !p.eof() && !unicode.IsSpace(p.peek()) && p.peek() != '"' {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// quoted reads a "..." string starting at the current quote.
+func (p *queryParser) quoted() (string, error) {
+	start := p.pos
+	p.pos++
+	for !p.eof() && p.peek() != '"' {
+		p.pos++
+	}
+	if p.eof() {
+		return "", p.errorf(start, "unterminated quote")
+	}
+	value := strings.TrimSpace(string(p.src[start+1 : p.pos]))
+	p.pos++
+	return value, nil
+}
+
+func (p *queryParser) errorf(pos int, format string, args ...any) error {
+	return &QuerySyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) applyField(
+	q *Query, field queryField, name, value string, pos int,
+) error {
+	if value == "" {
+		return p.errorf(pos, "missing value for %q", name)
+	}
+
+	switch field {
+	case fieldAuthor:
+		q.Filters.Author = value
+	case fieldCategory:
+		q.Filters.Categories = append(q.Filters.Categories, value)
+	case fieldFormat:
+		q.Filters.Formats = append(q.Filters.Formats, strings.ToLower(value))
+	case fieldDocument:
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return p.errorf(pos, "invalid document id %q", value)
+		}
+		q.Filters.DocumentIDs = append(q.Filters.DocumentIDs, id)
+	case fieldYear:
+		from, to, err := parseRange(p, value, pos, parseYear, stepYear)
+		if err != nil {
+			return err
+		}
+		if from != nil {
+			q.Filters.YearFrom = from
+		}
+		if to != nil {
+			q.Filters.YearTo = to
+		}
+	case fieldUploaded:
+		from, to, err := parseRange(p, value, pos, parseDate, stepDate)
+		if err != nil {
+			return err
+		}
+		if from != nil {
+			q.Filters.UploadedFrom = from
+		}
+		if to != nil {
+			end := to.AddDate(0, 0, 1).Add(-time.Microsecond)
+			q.Filters.UploadedTo = &end
+		}
+	}
+	return nil
+}
+
+func parseYear(s string) (int, bool) {
+	y, err := strconv.Atoi(s)
+	return y, err == nil
+}
+
+func stepYear(y, n int) int { return y + n }
+
+func parseDate(s string) (time.Time, bool) {
+	t, err := time.Parse(time.DateOnly, s)
+	return t, err == nil
+}
+
+func stepDate(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) }
+
+// parseRange handles "v", "=v", ">v", ">=v", "<v", "<=v" and "from..to"
+// (either side of the range may be empty). Bounds are inclusive, step
+// moves a value by n units for the strict comparisons.
+func parseRange[T any](
+	p *queryParser,
+	value string,
+	pos int,
+	parse func(string) (T, bool),
+	step func(T, int) T,
+) (*T, *T, error) {
+	bound := func(s string, at int) (*T, error) {
+		v, ok := parse(s)
+		if !ok {
+			return nil, p.errorf(at, "invalid value %q", s)
+		}
+		return &v, nil
+	}
+
+	if from, to, ok := strings.Cut(value, ".."); ok {
+		var lo, hi *T
+		var err error
+		if from != "" {
+			if lo, err = bound(from, pos); err != nil {
+				return nil, nil, err
+			}
+		}
+		if to != "" {
+			if hi, err = bound(to, pos+len([]rune(from))+2); err != nil {
+				return nil, nil, err
+			}
+		}
+		if lo == nil && hi == nil {
+			return nil, nil, p.errorf(pos, "empty range")
+		}
+		return lo, hi, nil
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		rest, ok := strings.CutPrefix(value, op)
+		if !ok {
+			continue
+		}
+		v, err := bound(rest, pos+len(op))
+		if err != nil {
+			return nil, nil, err
+		}
+		switch op {
+		case ">=":
+			return v, nil, nil
+		case "<=":
+			return nil, v, nil
+		case ">":
+			n := step(*v, 1)
+			return &n, nil, nil
+		case "<":
+			n := step(*v, -1)
+			return nil, &n, nil
+		default:
+			return v, v, nil
+		}
+	}
+
+	v, err := bound(value, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, v, nil
+}
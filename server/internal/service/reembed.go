@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/AndB0ndar/doc-archive/internal/config"
+	"github.com/AndB0ndar/doc-archive/internal/repository"
+)
+
+var ErrModelMismatch = fmt.Errorf("embedding model mismatch")
+
+// Pause before retrying a failed re-embedding batch or pass
+const reembedRetryDelay = 30 * time.Second
+
+// CheckEmbeddings compares the model served by the embedder and
+// EMBEDDING_DIMENSION with the stored chunk vectors before the server
+// starts, so a model switch is not mixed silently with old vectors.
+// An archive without vectors is resized to the configured dimension.
+func CheckEmbeddings(
+	ctx context.Context,
+	cfg *config.Config,
+	chunkRepo *repository.ChunkRepository,
+	embedder Embedder,
+) error {
+	model, err := embedder.Health(ctx)
+	if err != nil {
+		return fmt.Errorf("check embedder: %w", err)
+	}
+	slog.Info("embedder model", "model", model, "dimension", cfg.EmbeddingDimension)
+
+	stored, err := chunkRepo.EmbeddingModels()
+	if err != nil {
+		return err
+	}
+	columnDim, err := chunkRepo.EmbeddingColumnDimension()
+	if err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		if columnDim != cfg.EmbeddingDimension {
+			slog.Info("resizing embedding columns", "from", columnDim, "to", cfg.EmbeddingDimension)
+			return chunkRepo.ResizeEmbeddings(cfg.EmbeddingDimension)
+		}
+		return nil
+	}
+	if columnDim != cfg.EmbeddingDimension {
+		return fmt.Errorf(
+			"%w: chunks store %d-dimensional vectors, EMBEDDING_DIMENSION is %d; "+
+				"re-embed them with REEMBED_EMBEDDER_* first",
+			ErrModelMismatch, columnDim, cfg.EmbeddingDimension,
+		)
+	}
+
+	for _, s := range stored {
+		if s.Model == "" {
+			// Stored before models were tracked, assume the current one
+			tagged, err := chunkRepo.TagEmbeddingModel(model)
+			if err != nil {
+				return err
+			}
+			slog.Warn("chunks without embedding model attributed to the embedder model",
+				"model", model, "chunks", tagged)
+			continue
+		}
+		if s.Model != model {
+			return fmt.Errorf(
+				"%w: %d chunks are embedded with %q, the embedder serves %q; "+
+					"point EMBEDDER_* at %q and re-embed with REEMBED_EMBEDDER_*",
+				ErrModelMismatch, s.Chunks, s.Model, model, s.Model,
+			)
+		}
+	}
+	return nil
+}
+
+// Reembedder embeds every chunk again with the target embedder while
+// search keeps using the current vectors, then switches both in one
+// transaction.
+type Reembedder struct {
+	cfg       *config.Config
+	chunkRepo *repository.ChunkRepository
+	active    *EmbeddingCache // used by search and uploads, switched at the cutover
+	target    Embedder
+	cache     *EmbeddingCache // chunk texts embedded by the target
+
+	// Progress of the current pass over the chunks
+	after  int64
+	failed int
+}
+
+func NewReembedder(
+	cfg *config.Config,
+	chunkRepo *repository.ChunkRepository,
+	active *EmbeddingCache,
+	cacheRepo *repository.EmbeddingCacheRepository,
+) (*Reembedder, error) {
+	target, err := newEmbedder(cfg.Reembed.Embedder)
+	if err != nil {
+		return nil, err
+	}
+	return &Reembedder{
+		cfg:       cfg,
+		chunkRepo: chunkRepo,
+		active:    active,
+		target:    target,
+		cache:     NewEmbeddingCache(target, 0, 0, cacheRepo),
+	}, nil
+}
+
+// Run re-embeds in batches until every chunk has a vector of the target
+// model, then cuts over. Chunks that fail are skipped and retried by the
+// next pass over the chunks; batches stopped by an unavailable embedder
+// are retried until ctx is done.
+func (r *Reembedder) Run(ctx context.Context) error {
+	model, err := r.target.Health(ctx)
+	if err != nil {
+		return fmt.Errorf("check target embedder: %w", err)
+	}
+	if model == r.active.Model() {
+		slog.Info("re-embedding skipped, target serves the current model", "model", model)
+		return nil
+	}
+	slog.Info("re-embedding chunks", "from", r.active.Model(), "to", model)
+	go MonitorEmbedder(ctx, r.target)
+	if err := r.cache.Invalidate(ctx, r.active.Model(), model); err != nil {
+		slog.Warn("embedding cache invalidation failed", "error", err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := r.batch(ctx, model)
+		if err != nil {
+			slog.Error("re-embedding batch failed", "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(reembedRetryDelay):
+			}
+			continue
+		}
+		if n > 0 {
+			continue
+		}
+
+		if r.failed > 0 {
+			slog.Warn("re-embedding pass left failed chunks, retrying them",
+				"failed", r.failed, "retry_in", reembedRetryDelay)
+			r.after, r.failed = 0, 0
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(reembedRetryDelay):
+			}
+			continue
+		}
+		dimension, err := r.chunkRepo.CutoverEmbeddings(model)
+		if errors.Is(err, repository.ErrReembedPending) {
+			// Chunks added since the pass started
+			r.after = 0
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cutover: %w", err)
+		}
+		r.active.switchTo(r.target, dimension)
+		if err := r.cache.Invalidate(ctx, model); err != nil {
+			slog.Warn("embedding cache invalidation failed", "error", err)
+		}
+		slog.Info("re-embedding finished, search uses the new model",
+			"model", model, "dimension", dimension,
+			"hint", "move REEMBED_EMBEDDER_* to EMBEDDER_* and set EMBEDDING_DIMENSION")
+		return nil
+	}
+}
+
+// batch re-embeds the next chunks of the pass and returns how many were
+// taken. A chunk that fails is logged, counted and skipped; the batch
+// stops early only when no embedder is available or ctx is done.
+func (r *Reembedder) batch(ctx context.Context, model string) (int, error) {
+	chunks, err := r.chunkRepo.PendingReembed(model, r.after, r.cfg.Reembed.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i, chunk := range chunks {
+		embedding, err := r.cache.EmbedChunk(ctx, chunk.Content)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, errEmbedderUnavailable) {
+				return i, fmt.Errorf("embed chunk %d: %w", chunk.ID, err)
+			}
+			slog.Warn("re-embedding failed to embed chunk", "chunk_id", chunk.ID, "error", err)
+			r.failed++
+			r.after = chunk.ID
+			continue
+		}
+		if err := r.chunkRepo.SetNextEmbedding(chunk.ID, embedding, model); err != nil {
+			return i, err
+		}
+		r.after = chunk.ID
+	}
+	if len(chunks) > 0 {
+		done, total, err := r.chunkRepo.ReembedProgress(model)
+		if err == nil {
+			slog.Info("re-embedding progress",
+				"done", done, "total", total, "failed", r.failed)
+		}
+	}
+	return len(chunks), nil
+}
@@ -1,8 +1,11 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/AndB0ndar/doc-archive/internal/config"
 	"github.com/AndB0ndar/doc-archive/internal/models"
@@ -10,74 +13,521 @@ import (
 )
 
 type SearchService struct {
-	cfg            *config.Config
-	chunkRepo      *repository.ChunkRepository
-	embedderClient *Embedder
+	cfg        *config.Config
+	chunkRepo  *repository.ChunkRepository
+	docRepo    *repository.DocumentRepository
+	embeddings *EmbeddingCache
 }
 
 func NewSearchService(
 	cfg *config.Config,
 	repo *repository.ChunkRepository,
-	embedder *Embedder,
+	docRepo *repository.DocumentRepository,
+	embeddings *EmbeddingCache,
 ) *SearchService {
 	return &SearchService{
-		cfg:            cfg,
-		chunkRepo:      repo,
-		embedderClient: embedder,
+		cfg:        cfg,
+		chunkRepo:  repo,
+		docRepo:    docRepo,
+		embeddings: embeddings,
 	}
 }
 
 type SearchRequest struct {
-	Query  string
-	Type   string
-	UserID int
-	Limit  int
+	Query     string
+	Type      string
+	UserID    int
+	Limit     int
+	Filters   models.SearchFilters
+	Highlight *HighlightOptions
+	GroupBy   string // "" or "document"
+	GroupSize int    // chunks per document when grouping
+	MMR       bool
+	MMRLambda *float64 // DefaultMMRLambda when nil
+	Cursor    string   // next_cursor of the previous page
+	WithTotal bool     // count the approximate number of hits
+	MinScore  float64  // drop results scoring below it (0..1)
+	Facets    []string // facet fields: category, year, author, format
+	FacetSize int      // values per facet
+	Explain   bool     // report scores, applied filters and timings
+	EfSearch  int      // hnsw.ef_search override, 0 sizes it from the limit
+	Probes    int      // ivfflat.probes override, 0 uses IVFFLAT_PROBES
+	// OnStage, when set, receives the results of every completed stage
+	// ("candidates" before MMR, "results"); an error aborts the search.
+	OnStage func(stage string, results []models.ChunkSearchResponse) error
+
+	ctx       context.Context // of the caller, cancels the embedder call
+	after     *models.SearchCursor
+	embedding []float32 // query embedding, computed once per request
+	parsed    *Query
+	trace     *searchTrace
 }
 
 func (r *SearchRequest) Validate(defaultLimit, maxLimit int) error {
 	r.Query = strings.TrimSpace(r.Query)
 	if r.Query == "" {
+		// Results are ranked by the text, filters alone have no order
+		if r.parsed != nil && r.parsed.qualified {
+			return ErrNoQueryText
+		}
 		return ErrEmptyQuery
 	}
 	r.Type = strings.ToLower(r.Type)
-	if r.Type != "" && r.Type != "text" && r.Type != "vector" && r.Type != "semantic" {
+	if r.Type != "" && r.Type != "text" && r.Type != "vector" && r.Type != "semantic" &&
+		r.Type != "document" {
 		return ErrInvalidType
 	}
 	if r.Type == "" {
 		r.Type = "text"
 	}
+	if err := validateFilters(&r.Filters); err != nil {
+		return err
+	}
+	r.GroupBy = strings.ToLower(r.GroupBy)
+	if r.GroupBy != "" && r.GroupBy != "document" {
+		return ErrInvalidGroupBy
+	}
+	if r.GroupBy != "" && r.Type == "document" {
+		return fmt.Errorf("%w: type=document already returns documents", ErrInvalidGroupBy)
+	}
+	if r.GroupSize <= 0 {
+		r.GroupSize = defaultGroupSize
+	}
+	r.GroupSize = min(r.GroupSize, maxGroupSize)
+	if r.MMR && r.Type != "vector" && r.Type != "semantic" {
+		return fmt.Errorf("%w: mmr requires semantic search", ErrInvalidMMR)
+	}
+	if r.MMRLambda != nil && (*r.MMRLambda < 0 || *r.MMRLambda > 1) {
+		return fmt.Errorf("%w: mmr_lambda must be between 0 and 1", ErrInvalidMMR)
+	}
+	if r.MinScore < 0 || r.MinScore > 1 {
+		return fmt.Errorf("%w: min_score must be between 0 and 1", ErrInvalidFilter)
+	}
+	if r.EfSearch < 0 || r.EfSearch > repository.MaxEfSearch {
+		return fmt.Errorf(
+			"%w: ef_search must be between 1 and %d", ErrInvalidScan, repository.MaxEfSearch,
+		)
+	}
+	if r.Probes < 0 || r.Probes > repository.MaxProbes {
+		return fmt.Errorf(
+			"%w: probes must be between 1 and %d", ErrInvalidScan, repository.MaxProbes,
+		)
+	}
 	if r.Limit <= 0 {
 		r.Limit = defaultLimit
 	}
 	if r.Limit > maxLimit {
 		r.Limit = maxLimit
 	}
+	for i, facet := range r.Facets {
+		r.Facets[i] = strings.ToLower(strings.TrimSpace(facet))
+		if !repository.IsFacetField(r.Facets[i]) {
+			return fmt.Errorf("%w: unknown facet %q", ErrInvalidFacet, facet)
+		}
+	}
+	if r.FacetSize <= 0 {
+		r.FacetSize = defaultFacetSize
+	}
+	r.FacetSize = min(r.FacetSize, maxFacetSize)
+	if r.Cursor != "" {
+		if r.GroupBy != "" || r.MMR || r.Type == "document" {
+			return fmt.Errorf(
+				"%w: pagination is not supported with group_by, mmr or type=document",
+				ErrInvalidCursor,
+			)
+		}
+		after, err := decodeCursor(r.Cursor)
+		if err != nil {
+			return err
+		}
+		if after.Query != r.fingerprint() {
+			return fmt.Errorf(
+				"%w: cursor belongs to a different query", ErrInvalidCursor,
+			)
+		}
+		r.after = after
+	}
 	return nil
 }
 
 var (
-	ErrEmptyQuery  = fmt.Errorf("empty query")
-	ErrInvalidType = fmt.Errorf("invalid search type, use 'text' or 'semantic'")
-	ErrEmbedding   = fmt.Errorf("failed to get embedding")
+	ErrEmptyQuery     = fmt.Errorf("empty query")
+	ErrNoQueryText    = fmt.Errorf("query has qualifiers but no text to search for")
+	ErrInvalidType    = fmt.Errorf("invalid search type, use 'text', 'semantic' or 'document'")
+	ErrEmbedding      = fmt.Errorf("failed to get embedding")
+	ErrInvalidFilter  = fmt.Errorf("invalid search filter")
+	ErrInvalidGroupBy = fmt.Errorf("invalid group_by, use 'document'")
+	ErrInvalidMMR     = fmt.Errorf("invalid mmr parameters")
+	ErrInvalidFacet   = fmt.Errorf("invalid facets, use category, year, author or format")
+	ErrInvalidScan    = fmt.Errorf("invalid vector index scan parameters")
+)
+
+// ErrInvalidDocumentSearchType rejects type=document within a document,
+// whose chunks are searched rather than documents.
+var ErrInvalidDocumentSearchType = fmt.Errorf("invalid search type, use 'text' or 'semantic'")
+
+const (
+	defaultFacetSize = 10
+	maxFacetSize     = 100
+
+	// Semantic search matches every chunk, so facets of a semantic query
+	// count the documents of its nearest chunks only.
+	semanticFacetCandidates = 500
 )
 
+func validateFilters(f *models.SearchFilters) error {
+	if f.YearFrom != nil && f.YearTo != nil && *f.YearFrom > *f.YearTo {
+		return fmt.Errorf("%w: year_from is greater than year_to", ErrInvalidFilter)
+	}
+	if f.UploadedFrom != nil && f.UploadedTo != nil &&
+		f.UploadedFrom.After(*f.UploadedTo) {
+		return fmt.Errorf(
+			"%w: uploaded_from is later than uploaded_to", ErrInvalidFilter,
+		)
+	}
+	for i, format := range f.Formats {
+		f.Formats[i] = strings.ToLower(strings.TrimPrefix(format, "."))
+	}
+	return nil
+}
+
 func (s *SearchService) Search(
-	req SearchRequest,
+	ctx context.Context, req SearchRequest,
 ) ([]models.ChunkSearchResponse, error) {
 	if err := req.Validate(s.cfg.SearchDefaultLimit, s.cfg.SearchMaxLimit); err != nil {
 		return nil, err
 	}
+	req.ctx = ctx
+	return s.searchChunks(req)
+}
+
+// SearchPage runs the request as one page of /search: chunks (documents
+// with group_by or type=document), the cursor of the next page and the
+// optional total.
+func (s *SearchService) SearchPage(
+	ctx context.Context, req SearchRequest,
+) (*models.SearchResponse, error) {
+	if err := req.Validate(s.cfg.SearchDefaultLimit, s.cfg.SearchMaxLimit); err != nil {
+		return nil, err
+	}
+	req.ctx = ctx
+	start := time.Now()
+	if req.Explain {
+		req.trace = &searchTrace{}
+		req.trace.query.WithPlan = s.cfg.IsAdmin(req.UserID)
+	}
+	resp := &models.SearchResponse{Results: []models.ChunkSearchResponse{}}
+	if err := s.embedQuery(&req); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.Type == "document":
+		docs, err := s.docRepo.SearchDocuments(req.embedding, req.UserID, req.searchOptions())
+		if err != nil {
+			return nil, err
+		}
+		resp.DocumentResults = docs
+	case req.GroupBy != "":
+		docs, err := s.searchGrouped(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Documents = docs
+		if req.trace != nil {
+			for i := range docs {
+				explainResults(docs[i].Chunks, req.Type)
+			}
+		}
+	default:
+		// One extra row tells whether there is a next page
+		limit := req.Limit
+		if !req.MMR {
+			req.Limit++
+		}
+		results, err := s.searchChunks(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) > limit {
+			results = results[:limit]
+			last := results[limit-1]
+			next := encodeCursor(models.SearchCursor{
+				Score:   last.Similarity,
+				ChunkID: last.ChunkID,
+				Query:   req.fingerprint(),
+			})
+			resp.NextCursor = &next
+		}
+		if results != nil {
+			resp.Results = results
+		}
+		if req.trace != nil {
+			explainResults(resp.Results, req.Type)
+		}
+		if err := req.emit("results", resp.Results); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.WithTotal {
+		total, err := s.countHits(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Total = &total
+		resp.TotalRelation = "eq"
+		if total >= s.cfg.SearchTotalCap {
+			resp.TotalRelation = "gte"
+		}
+	}
+
+	if len(req.Facets) > 0 {
+		facets, err := s.facets(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Facets = facets
+	}
+
+	if req.trace != nil {
+		s.explain(req, resp, time.Since(start))
+	}
+	return resp, nil
+}
+
+// facets counts matching documents per value of every requested facet.
+// A facet ignores the filter on its own field, so the other values stay
+// visible after one of them has been selected.
+func (s *SearchService) facets(
+	req SearchRequest,
+) (map[string][]models.FacetCount, error) {
+	facets := make(map[string][]models.FacetCount, len(req.Facets))
+	for _, field := range req.Facets {
+		if _, done := facets[field]; done {
+			continue
+		}
+		filters := withoutFacetFilter(req.Filters, field)
+		var counts []models.FacetCount
+		var err error
+		switch req.Type {
+		case "text":
+			counts, err = s.chunkRepo.TextFacets(
+				req.Query, req.UserID, filters, field, req.FacetSize,
+			)
+		case "document":
+			counts, err = s.docRepo.DocumentFacets(
+				req.embedding, req.UserID, filters, field,
+				req.FacetSize, semanticFacetCandidates,
+			)
+		default:
+			counts, err = s.chunkRepo.SemanticFacets(
+				req.embedding, req.UserID, filters, field,
+				req.FacetSize, semanticFacetCandidates,
+			)
+		}
+		if err != nil {
+			return nil, err
+		}
+		facets[field] = counts
+	}
+	return facets, nil
+}
+
+func withoutFacetFilter(f models.SearchFilters, field string) models.SearchFilters {
+	switch field {
+	case "category":
+		f.Categories = nil
+	case "year":
+		f.YearFrom, f.YearTo = nil, nil
+	case "author":
+		f.Author = ""
+	case "format":
+		f.Formats = nil
+	}
+	return f
+}
+
+// embedQuery embeds the query of a semantic request once, so the
+// search and its facets share the same vector.
+func (s *SearchService) embedQuery(req *SearchRequest) error {
+	if req.Type == "text" || req.embedding != nil {
+		return nil
+	}
+	start := time.Now()
+	embedding, err := s.embeddings.EmbedQuery(req.ctx, req.Query)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEmbedding, err)
+	}
+	if req.trace != nil {
+		req.trace.embedding = time.Since(start)
+	}
+	req.embedding = embedding
+	return nil
+}
+
+// countHits counts matching chunks up to the configured cap: chunks
+// similar to the query for text search, every filtered chunk with an
+// embedding (above min_score) for semantic search and likewise documents
+// for document search.
+func (s *SearchService) countHits(req SearchRequest) (int, error) {
+	switch req.Type {
+	case "text":
+		return s.chunkRepo.CountTextMatches(
+			req.Query, req.UserID, req.Filters, req.MinScore, s.cfg.SearchTotalCap,
+		)
+	case "document":
+		return s.docRepo.CountDocuments(
+			req.embedding, req.UserID, req.Filters, req.MinScore, s.cfg.SearchTotalCap,
+		)
+	}
+	return s.chunkRepo.CountEmbedded(
+		req.embedding, req.UserID, req.Filters, req.MinScore, s.cfg.SearchTotalCap,
+	)
+}
+
+// searchGrouped returns up to req.Limit documents, each with its best
+// supporting chunks.
+func (s *SearchService) searchGrouped(
+	req SearchRequest,
+) ([]models.DocumentSearchResult, error) {
+	docLimit := req.Limit
+	req.Limit = min(docLimit*groupOverfetchFactor, maxGroupCandidates)
+
+	results, err := s.searchChunks(req)
+	if err != nil {
+		return nil, err
+	}
+	return GroupByDocument(results, docLimit, req.GroupSize), nil
+}
 
+func (s *SearchService) searchChunks(
+	req SearchRequest,
+) ([]models.ChunkSearchResponse, error) {
+	var results []models.ChunkSearchResponse
+	var err error
 	switch req.Type {
 	case "", "text":
-		return s.chunkRepo.FullTextSearchChunks(req.Query, req.UserID, req.Limit)
+		results, err = s.chunkRepo.FullTextSearchChunks(
+			req.Query, req.UserID, req.searchOptions(),
+		)
 	case "vector", "semantic":
-		embedding, err := s.embedderClient.Embed(req.Query)
-		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrEmbedding, err)
+		if err := s.embedQuery(&req); err != nil {
+			return nil, err
 		}
-		return s.chunkRepo.SemanticSearchChunks(embedding, req.UserID, req.Limit)
+		if req.MMR {
+			results, err = s.searchMMR(req.embedding, req)
+			break
+		}
+		results, err = s.chunkRepo.SemanticSearchChunks(
+			req.embedding, req.UserID, req.searchOptions(),
+		)
+	case "document":
+		return nil, fmt.Errorf("%w: type=document returns documents, not chunks", ErrInvalidType)
 	default:
 		return nil, ErrInvalidType
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Highlight != nil {
+		Highlight(results, req.Query, req.Filters.Phrases, *req.Highlight)
+	}
+	return results, nil
+}
+
+// searchMMR over-fetches nearest chunks with their embeddings and keeps
+// a diverse top req.Limit of them.
+func (s *SearchService) searchMMR(
+	embedding []float32, req SearchRequest,
+) ([]models.ChunkSearchResponse, error) {
+	opts := req.searchOptions()
+	opts.Limit = min(req.Limit*mmrOverfetchFactor, maxMMRCandidates)
+	candidates, err := s.chunkRepo.SemanticSearchCandidates(
+		embedding, req.UserID, opts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.emit("candidates", candidates[:min(len(candidates), req.Limit)]); err != nil {
+		return nil, err
+	}
+	lambda := DefaultMMRLambda
+	if req.MMRLambda != nil {
+		lambda = *req.MMRLambda
+	}
+	start := time.Now()
+	selected, scores := mmrSelect(candidates, req.Limit, lambda)
+	if req.trace != nil {
+		req.trace.rerank = time.Since(start)
+		for i := range selected {
+			selected[i].Explain = &models.ResultExplain{RerankScore: &scores[i]}
+		}
+	}
+	return selected, nil
+}
+
+func (r *SearchRequest) emit(
+	stage string, results []models.ChunkSearchResponse,
+) error {
+	if r.OnStage == nil {
+		return nil
+	}
+	return r.OnStage(stage, results)
+}
+
+func (r *SearchRequest) searchOptions() repository.SearchOptions {
+	return repository.SearchOptions{
+		Filters:  r.Filters,
+		After:    r.after,
+		Limit:    r.Limit,
+		MinScore: r.MinScore,
+		Scan:     repository.VectorScan{EfSearch: r.EfSearch, Probes: r.Probes},
+		Trace:    r.trace.queryTrace(),
+	}
+}
+
+// SearchDocument searches a single document and lists the hits in reading
+// order (by page, then chunk) with highlighting on by default.
+func (s *SearchService) SearchDocument(
+	ctx context.Context, req SearchRequest, documentID int,
+) ([]models.ChunkSearchResponse, error) {
+	req.Filters.DocumentIDs = []int{documentID}
+	req.GroupBy, req.MMR, req.Cursor = "", false, ""
+	if req.Highlight == nil {
+		req.Highlight = &HighlightOptions{}
+	}
+	if err := req.Validate(s.cfg.SearchDefaultLimit, s.cfg.SearchMaxLimit); err != nil {
+		return nil, err
+	}
+	if req.Type == "document" {
+		return nil, ErrInvalidDocumentSearchType
+	}
+	req.ctx = ctx
+
+	results, err := s.searchChunks(req)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(results, func(i, j int) bool {
+		pi, pj := pageOf(results[i]), pageOf(results[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return results[i].ChunkIndex < results[j].ChunkIndex
+	})
+	if results == nil {
+		results = []models.ChunkSearchResponse{}
+	}
+	return results, nil
+}
+
+// pageOf is the first page of a result, 0 for chunks stored before page
+// numbers were kept.
+func pageOf(r models.ChunkSearchResponse) int {
+	if r.PageStart == nil {
+		return 0
+	}
+	return *r.PageStart
 }
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AndB0ndar/doc-archive/internal/models"
+	"github.com/AndB0ndar/doc-archive/internal/repository"
+)
+
+const (
+	// Longer inputs are rejected, each passage costs an embedding call
+	maxSimilarPassages = 50
+	// Nearest chunks fetched for every input passage
+	similarChunksPerPassage = 20
+	defaultSimilarPairs     = 3
+	maxSimilarPairs         = 10
+)
+
+var ErrInvalidInput = fmt.Errorf("invalid input")
+
+type SimilarRequest struct {
+	Text    string
+	UserID  int
+	Limit   int // documents
+	Pairs   int // passage pairs per document
+	Filters models.SearchFilters
+}
+
+// SearchSimilar chunks and embeds the text like an uploaded document and
+// ranks archived documents by how many input passages they overlap and
+// how closely.
+func (s *SearchService) SearchSimilar(
+	ctx context.Context, req SimilarRequest,
+) (*models.SimilarSearchResponse, error) {
+	if strings.TrimSpace(req.Text) == "" {
+		return nil, fmt.Errorf("%w: text or file is required", ErrInvalidInput)
+	}
+	if err := validateFilters(&req.Filters); err != nil {
+		return nil, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = s.cfg.SearchDefaultLimit
+	}
+	req.Limit = min(req.Limit, s.cfg.SearchMaxLimit)
+	if req.Pairs <= 0 {
+		req.Pairs = defaultSimilarPairs
+	}
+	req.Pairs = min(req.Pairs, maxSimilarPairs)
+
+	passages := Chunk(req.Text, s.cfg.ChunkSize, s.cfg.ChunkOverlap)
+	if len(passages) > maxSimilarPassages {
+		return nil, fmt.Errorf(
+			"%w: input is longer than %d passages", ErrInvalidInput, maxSimilarPassages,
+		)
+	}
+
+	type docMatch struct {
+		result models.SimilarDocumentResult
+		// best pair per input passage
+		best map[int]models.PassagePair
+	}
+	docs := make(map[int]*docMatch)
+
+	for i, passage := range passages {
+		// User input, kept out of both caches
+		embedding, err := s.embeddings.Embed(ctx, passage)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrEmbedding, err)
+		}
+		chunks, err := s.chunkRepo.SemanticSearchChunks(
+			embedding, req.UserID, repository.SearchOptions{
+				Filters: req.Filters,
+				Limit:   similarChunksPerPassage,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range chunks {
+			d, ok := docs[c.DocumentID]
+			if !ok {
+				d = &docMatch{
+					result: models.SimilarDocumentResult{
+						DocumentID: c.DocumentID,
+						Title:      c.Title,
+						Authors:    c.Authors,
+						Year:       c.Year,
+						Category:   c.Category,
+					},
+					best: make(map[int]models.PassagePair),
+				}
+				docs[c.DocumentID] = d
+			}
+			if prev, seen := d.best[i]; seen && prev.Similarity >= c.Similarity {
+				continue
+			}
+			d.best[i] = models.PassagePair{
+				InputIndex: i,
+				Input:      passage,
+				ChunkID:    c.ChunkID,
+				ChunkIndex: c.ChunkIndex,
+				Content:    c.Content,
+				Similarity: c.Similarity,
+			}
+		}
+	}
+
+	results := make([]models.SimilarDocumentResult, 0, len(docs))
+	for _, d := range docs {
+		pairs := make([]models.PassagePair, 0, len(d.best))
+		total := 0.0
+		for _, p := range d.best {
+			pairs = append(pairs, p)
+			total += p.Similarity
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			if pairs[i].Similarity != pairs[j].Similarity {
+				return pairs[i].Similarity > pairs[j].Similarity
+			}
+			return pairs[i].InputIndex < pairs[j].InputIndex
+		})
+		d.result.Score = total / float64(len(passages))
+		d.result.Passages = pairs[:min(len(pairs), req.Pairs)]
+		results = append(results, d.result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].DocumentID < results[j].DocumentID
+	})
+
+	return &models.SimilarSearchResponse{
+		InputPassages: len(passages),
+		Results:       results[:min(len(results), req.Limit)],
+	}, nil
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_messages_conversation_id;
+DROP TABLE IF EXISTS messages;
+
+DROP INDEX IF EXISTS idx_conversations_user_id;
+DROP TABLE IF EXISTS conversations;
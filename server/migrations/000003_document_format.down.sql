@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_documents_user_year;
+DROP INDEX IF EXISTS idx_documents_user_category;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS format;
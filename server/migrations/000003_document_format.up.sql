@@ -0,0 +1,4 @@
+ALTER TABLE documents ADD COLUMN format TEXT NOT NULL DEFAULT 'pdf';
+
+CREATE INDEX idx_documents_user_category ON documents(user_id, category);
+CREATE INDEX idx_documents_user_year ON documents(user_id, year);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_documents_embedding;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS embedding;
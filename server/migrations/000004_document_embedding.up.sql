@@ -0,0 +1,12 @@
+-- Mean of the chunk embeddings, used to find similar documents
+ALTER TABLE documents ADD COLUMN embedding vector(384);
+
+UPDATE documents d
+SET embedding = (
+    SELECT avg(c.embedding)
+    FROM chunks c
+    WHERE c.document_id = d.id AND c.embedding IS NOT NULL
+);
+
+CREATE INDEX idx_documents_embedding ON documents
+    USING hnsw (embedding vector_cosine_ops);
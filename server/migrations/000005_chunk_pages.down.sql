@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_chunks_document_page;
+
+ALTER TABLE chunks DROP COLUMN IF EXISTS page_end;
+ALTER TABLE chunks DROP COLUMN IF EXISTS page_start;
@@ -0,0 +1,5 @@
+-- PDF pages spanned by the chunk, NULL for chunks stored before pages were tracked
+ALTER TABLE chunks ADD COLUMN page_start INT;
+ALTER TABLE chunks ADD COLUMN page_end INT;
+
+CREATE INDEX idx_chunks_document_page ON chunks(document_id, page_start);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_chunks_embedding;
+
+CREATE INDEX idx_chunks_embedding ON chunks
+    USING hnsw (embedding vector_cosine_ops);
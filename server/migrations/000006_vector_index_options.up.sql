@@ -0,0 +1,50 @@
+-- Rebuilds the chunk embedding index from the doc_archive.* settings the
+-- server sets on its connections (VECTOR_INDEX, VECTOR_STORAGE, HNSW_M,
+-- HNSW_EF_CONSTRUCTION, IVFFLAT_LISTS). To apply changed values, migrate
+-- down to version 5 and up again. IVFFlat lists are trained on the rows
+-- present at build time, so build it once the archive has data.
+DROP INDEX IF EXISTS idx_chunks_embedding;
+
+DO $$
+DECLARE
+    index_type TEXT := coalesce(nullif(current_setting('doc_archive.vector_index', true), ''), 'hnsw');
+    storage TEXT := coalesce(nullif(current_setting('doc_archive.vector_storage', true), ''), 'vector');
+    indexed TEXT := 'embedding';
+    ops TEXT := 'vector_cosine_ops';
+    params TEXT;
+BEGIN
+    IF storage = 'halfvec' THEN
+        -- Half-precision copy in the index only, the column keeps full
+        -- vectors. Sized like the column, which EMBEDDING_DIMENSION may have
+        -- changed.
+        indexed := format(
+            '(embedding::halfvec(%s))',
+            (SELECT atttypmod FROM pg_attribute
+             WHERE attrelid = 'chunks'::regclass AND attname = 'embedding')
+        );
+        ops := 'halfvec_cosine_ops';
+    ELSIF storage <> 'vector' THEN
+        RAISE EXCEPTION 'unknown vector storage "%"', storage;
+    END IF;
+
+    IF index_type = 'hnsw' THEN
+        params := format(
+            'm = %s, ef_construction = %s',
+            coalesce(nullif(current_setting('doc_archive.hnsw_m', true), ''), '16')::int,
+            coalesce(nullif(current_setting('doc_archive.hnsw_ef_construction', true), ''), '64')::int
+        );
+    ELSIF index_type = 'ivfflat' THEN
+        params := format(
+            'lists = %s',
+            coalesce(nullif(current_setting('doc_archive.ivfflat_lists', true), ''), '100')::int
+        );
+    ELSE
+        RAISE EXCEPTION 'unknown vector index "%"', index_type;
+    END IF;
+
+    EXECUTE format(
+        'CREATE INDEX idx_chunks_embedding ON chunks USING %s (%s %s) WITH (%s)',
+        index_type, indexed, ops, params
+    );
+END
+$$;
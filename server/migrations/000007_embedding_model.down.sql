@@ -0,0 +1,4 @@
+ALTER TABLE chunks DROP COLUMN IF EXISTS embedding_next_model;
+ALTER TABLE chunks DROP COLUMN IF EXISTS embedding_next;
+ALTER TABLE chunks DROP COLUMN IF EXISTS embedding_dim;
+ALTER TABLE chunks DROP COLUMN IF EXISTS embedding_model;
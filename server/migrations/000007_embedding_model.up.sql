@@ -0,0 +1,12 @@
+-- Model and dimension of the stored embedding. Vectors stored before models
+-- were tracked have a NULL model and are tagged with the embedder's model
+-- on the next start.
+ALTER TABLE chunks ADD COLUMN embedding_model TEXT;
+ALTER TABLE chunks ADD COLUMN embedding_dim INT;
+
+UPDATE chunks SET embedding_dim = vector_dims(embedding) WHERE embedding IS NOT NULL;
+
+-- Vectors of the model being migrated to (REEMBED_EMBEDDER_URL), not searched
+-- until the cutover moves them to embedding
+ALTER TABLE chunks ADD COLUMN embedding_next vector;
+ALTER TABLE chunks ADD COLUMN embedding_next_model TEXT;
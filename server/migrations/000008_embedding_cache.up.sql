@@ -0,0 +1,9 @@
+-- Embeddings of chunk texts by model, so identical text is not embedded
+-- again when documents are reprocessed or re-embedded
+CREATE TABLE embedding_cache (
+    model TEXT NOT NULL,
+    text_hash BYTEA NOT NULL, -- sha256 of the text
+    embedding vector NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (model, text_hash)
+);
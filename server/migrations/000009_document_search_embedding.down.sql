@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_documents_search_embedding;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS search_embedding;
+ALTER TABLE documents DROP COLUMN IF EXISTS abstract;
@@ -0,0 +1,18 @@
+-- Abstract (or first page) of the document, embedded into search_embedding
+ALTER TABLE documents ADD COLUMN abstract TEXT;
+
+-- Vector matched by type=document search: the embeddings of the title and
+-- authors, of the abstract and the chunk centroid combined. Sized like the
+-- other vectors, which EMBEDDING_DIMENSION may have changed.
+DO $$
+BEGIN
+    EXECUTE format(
+        'ALTER TABLE documents ADD COLUMN search_embedding vector(%s)',
+        (SELECT atttypmod FROM pg_attribute
+         WHERE attrelid = 'documents'::regclass AND attname = 'embedding')
+    );
+END
+$$;
+
+CREATE INDEX idx_documents_search_embedding ON documents
+    USING hnsw (search_embedding vector_cosine_ops);
@@ -0,0 +1,3 @@
+-- When the backfill last chunked again a document without chunks. Such a
+-- document yielded none or failed, so it is not taken again.
+ALTER TABLE documents ADD COLUMN reprocessed_at TIMESTAMPTZ;
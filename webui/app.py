@@ -465,11 +465,15 @@ def search():
     if not query:
         return '', 400
 
-    results, err = call_go_api_auth('/search', params={'q': query, 'type': search_type})
+    page, err = call_go_api_auth(
+        '/search',
+        params={'q': query, 'type': search_type, 'highlight': 'true', 'fragments': 1},
+    )
     if err:
         logger.error(f"Search error: {err}")
         return f"Search error: {err}", 500
 
+    results = (page or {}).get('results', [])
     return render_template('search_results.html', results=results)
 
 